@@ -0,0 +1,333 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"scrawling_dashboard/backend/crawler"
+	"scrawling_dashboard/backend/models"
+)
+
+const (
+	// maxBatchURLs caps the jobs of one batch, submitted or read from
+	// sitemaps.
+	maxBatchURLs     = 10000
+	maxBatchBodySize = 10 << 20
+)
+
+// batchEntry is one submitted URL with its position in the input.
+type batchEntry struct {
+	Line  int    `json:"line"`
+	Input string `json:"input"`
+
+	invalid string // why the entry could not be read as a URL
+}
+
+type acceptedEntry struct {
+	batchEntry
+	URL   string `json:"url"`
+	JobID int64  `json:"job_id"`
+}
+
+type rejectedEntry struct {
+	batchEntry
+	Reason string `json:"reason"`
+}
+
+// BatchCrawlHandler queues many URLs at once. The body is a JSON array of
+// URLs (or {"url": ...} objects), a JSON object {"urls": [...]} with crawl
+// options, a CSV file (a "url" column or the first column) or a plain text
+// list with one URL per line. CSV and text uploads may be sent as the "file"
+// field of a multipart form, and take their crawl options as JSON in the
+// "options" form or query value. Every URL is normalized and deduplicated;
+// the response lists accepted and rejected entries under one batch ID.
+func BatchCrawlHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBodySize)
+
+	source, entries, opts, err := readBatch(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(entries) == 0 {
+		http.Error(w, "No URLs submitted", http.StatusBadRequest)
+		return
+	}
+	if len(entries) > maxBatchURLs {
+		http.Error(w, fmt.Sprintf("Too many URLs (max %d)", maxBatchURLs), http.StatusBadRequest)
+		return
+	}
+	if err := validateOptions(&opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if opts.Project == "" {
+		opts.Project = sessionUser(r)
+	}
+
+	var (
+		urls     []string
+		valid    []batchEntry
+		rejected = []rejectedEntry{}
+		seen     = make(map[string]int)
+	)
+	for _, entry := range entries {
+		if entry.invalid != "" {
+			rejected = append(rejected, rejectedEntry{entry, entry.invalid})
+			continue
+		}
+		url, err := crawler.NormalizeURL(entry.Input)
+		if err != nil {
+			rejected = append(rejected, rejectedEntry{entry, err.Error()})
+			continue
+		}
+		if line, ok := seen[url]; ok {
+			rejected = append(rejected, rejectedEntry{entry, fmt.Sprintf("duplicate of line %d", line)})
+			continue
+		}
+		seen[url] = entry.Line
+		urls = append(urls, url)
+		valid = append(valid, entry)
+	}
+	if len(urls) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":  "No valid URLs submitted",
+			"accepted": []acceptedEntry{},
+			"rejected": rejected,
+		})
+		return
+	}
+
+	batchID, jobIDs, err := queueBatch(models.Batch{
+		Source:   source,
+		Total:    len(entries),
+		Accepted: len(urls),
+		Rejected: len(rejected),
+	}, urls, opts)
+	if err != nil {
+		log.Printf("Failed to create batch: %v\n", err)
+		http.Error(w, "Failed to queue batch", http.StatusInternalServerError)
+		return
+	}
+
+	accepted := make([]acceptedEntry, len(urls))
+	for i, url := range urls {
+		accepted[i] = acceptedEntry{valid[i], url, jobIDs[i]}
+	}
+	log.Printf("Batch %d: %d URL(s) accepted, %d rejected\n", batchID, len(accepted), len(rejected))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"batch_id": batchID,
+		"accepted": accepted,
+		"rejected": rejected,
+	})
+}
+
+// queueBatch stores a batch with a queued job for each of urls, in one
+// transaction, and returns the IDs of the batch and of its jobs.
+func queueBatch(batch models.Batch, urls []string, opts models.CrawlOptions) (int64, []int64, error) {
+	batchID, jobIDs, err := db.CreateBatch(batch, urls, opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	notifyWorkers()
+	return batchID, jobIDs, nil
+}
+
+// BatchHandler returns a batch with the state of each of its jobs and a
+// count of jobs per state.
+func BatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid batch ID", http.StatusBadRequest)
+		return
+	}
+	batch, err := db.GetBatch(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Batch not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Failed to load batch %d: %v\n", id, err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	jobs, err := db.BatchJobs(id)
+	if err != nil {
+		log.Printf("Failed to load jobs of batch %d: %v\n", id, err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, job := range jobs {
+		counts[job.State]++
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"batch":  batch,
+		"counts": counts,
+		"jobs":   jobs,
+	})
+}
+
+// readBatch parses a batch submission into its source format, entries and
+// crawl options.
+func readBatch(r *http.Request) (string, []batchEntry, models.CrawlOptions, error) {
+	var opts models.CrawlOptions
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType == "application/json" {
+		entries, opts, err := readJSONBatch(r.Body)
+		return "json", entries, opts, err
+	}
+
+	body := io.Reader(r.Body)
+	isCSV := mediaType == "text/csv"
+	if mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return "", nil, opts, errors.New("Missing file field")
+		}
+		defer file.Close()
+		body = file
+		isCSV = strings.EqualFold(filepath.Ext(header.Filename), ".csv") ||
+			header.Header.Get("Content-Type") == "text/csv"
+	}
+	if raw := r.FormValue("options"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &opts); err != nil {
+			return "", nil, opts, errors.New("Invalid options")
+		}
+	}
+
+	if isCSV {
+		entries, err := readCSVBatch(body)
+		return "csv", entries, opts, err
+	}
+	entries, err := readTextBatch(body)
+	return "text", entries, opts, err
+}
+
+// readJSONBatch reads a JSON array of URLs or {"url": ...} objects, or an
+// object holding such an array as "urls" next to crawl options.
+func readJSONBatch(body io.Reader) ([]batchEntry, models.CrawlOptions, error) {
+	var opts models.CrawlOptions
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, opts, errors.New("Failed to read body")
+	}
+
+	items := []json.RawMessage{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var payload struct {
+			URLs []json.RawMessage `json:"urls"`
+			models.CrawlOptions
+		}
+		if err := json.Unmarshal(trimmed, &payload); err != nil {
+			return nil, opts, errors.New("Invalid input")
+		}
+		items, opts = payload.URLs, payload.CrawlOptions
+	} else if err := json.Unmarshal(trimmed, &items); err != nil {
+		return nil, opts, errors.New("Invalid input")
+	}
+
+	entries := make([]batchEntry, 0, len(items))
+	for i, item := range items {
+		entry := batchEntry{Line: i + 1}
+		var object struct {
+			URL string `json:"url"`
+		}
+		if err := json.Unmarshal(item, &entry.Input); err != nil {
+			if err := json.Unmarshal(item, &object); err == nil {
+				entry.Input = object.URL
+			} else {
+				entry.Input = string(item)
+				entry.invalid = "not a URL string"
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, opts, nil
+}
+
+// readCSVBatch reads the "url" column of a CSV file with a header row, or
+// its first column otherwise.
+func readCSVBatch(body io.Reader) ([]batchEntry, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var entries []batchEntry
+	column := 0
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CSV: %v", err)
+		}
+		if line == 1 {
+			if i := headerColumn(record, "url"); i >= 0 {
+				column = i
+				continue
+			}
+		}
+		if column >= len(record) || strings.TrimSpace(record[column]) == "" {
+			continue
+		}
+		entries = append(entries, batchEntry{Line: line, Input: record[column]})
+	}
+	return entries, nil
+}
+
+// headerColumn returns the index of name in a CSV header row, or -1.
+func headerColumn(record []string, name string) int {
+	for i, field := range record {
+		if strings.EqualFold(strings.TrimSpace(field), name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// readTextBatch reads one URL per line, skipping blank lines and lines
+// starting with #.
+func readTextBatch(body io.Reader) ([]batchEntry, error) {
+	var entries []batchEntry
+	scanner := bufio.NewScanner(body)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		entries = append(entries, batchEntry{Line: line, Input: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.New("Failed to read body")
+	}
+	return entries, nil
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadBatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		format      string
+		inputs      []string
+		invalid     int
+	}{
+		{
+			name:        "json array",
+			contentType: "application/json",
+			body:        `["example.com", {"url": "https://example.org"}, 42]`,
+			format:      "json",
+			inputs:      []string{"example.com", "https://example.org", "42"},
+			invalid:     1,
+		},
+		{
+			name:        "json object",
+			contentType: "application/json",
+			body:        `{"urls": ["a.example"], "priority": "high"}`,
+			format:      "json",
+			inputs:      []string{"a.example"},
+		},
+		{
+			name:        "csv with header",
+			contentType: "text/csv",
+			body:        "name,url\nA,a.example\nB,\nC, c.example\n",
+			format:      "csv",
+			inputs:      []string{"a.example", "c.example"},
+		},
+		{
+			name:   "text",
+			body:   "# sites\na.example\n\n  b.example  \n",
+			format: "text",
+			inputs: []string{"a.example", "b.example"},
+		},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/api/crawl/batch", strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			r.Header.Set("Content-Type", tt.contentType)
+		}
+		format, entries, opts, err := readBatch(r)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if format != tt.format {
+			t.Errorf("%s: format %q, want %q", tt.name, format, tt.format)
+		}
+		var inputs []string
+		invalid := 0
+		for _, entry := range entries {
+			inputs = append(inputs, entry.Input)
+			if entry.invalid != "" {
+				invalid++
+			}
+		}
+		if strings.Join(inputs, " ") != strings.Join(tt.inputs, " ") || invalid != tt.invalid {
+			t.Errorf("%s: inputs %q with %d invalid, want %q with %d", tt.name, inputs, invalid, tt.inputs, tt.invalid)
+		}
+		if tt.name == "json object" && opts.Priority != "high" {
+			t.Errorf("%s: options %+v, want the priority next to the urls", tt.name, opts)
+		}
+	}
+
+	r := httptest.NewRequest("POST", "/api/crawl/batch", strings.NewReader(`{"urls": 1}`))
+	r.Header.Set("Content-Type", "application/json")
+	if _, _, _, err := readBatch(r); err == nil {
+		t.Error("an invalid JSON batch is accepted")
+	}
+}
+
+func TestBatchCrawlHandlerAllRejected(t *testing.T) {
+	store := useTestStore(t)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/crawl/batch", strings.NewReader(`["ftp://a.example/", "", 42]`))
+	req.Header.Set("Content-Type", "application/json")
+	BatchCrawlHandler(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		BatchID  int64           `json:"batch_id"`
+		Rejected []rejectedEntry `json:"rejected"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.BatchID != 0 || len(resp.Rejected) != 3 {
+		t.Errorf("response = %s, want every entry rejected and no batch", rec.Body)
+	}
+	if _, err := store.GetBatch(1); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("a batch was created: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"scrawling_dashboard/backend/database"
+)
+
+// cancelJobs cancels the unfinished jobs matching filter: queued and paused
+// jobs, including jobs waiting for a retry, are canceled at once; running
+// jobs are aborted by their worker, which records them as canceled. It
+// returns the IDs of the canceled jobs.
+func cancelJobs(filter database.JobFilter) ([]int64, error) {
+	ids, err := db.UnfinishedJobs(filter)
+	if err != nil {
+		return nil, err
+	}
+	canceled := []int64{}
+	for _, id := range ids {
+		ok, err := db.CancelJob(id)
+		if err != nil {
+			return canceled, err
+		}
+		if ok {
+			canceled = append(canceled, id)
+		}
+	}
+	notifyWorkers()
+	return canceled, nil
+}
+
+// CancelJobHandler cancels the job named by the {id} path value.
+func CancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	cancelByPathID(w, r, func(id int64) database.JobFilter { return database.JobFilter{ID: id} })
+}
+
+// CancelBatchHandler cancels every unfinished job of the batch named by the
+// {id} path value.
+func CancelBatchHandler(w http.ResponseWriter, r *http.Request) {
+	cancelByPathID(w, r, func(id int64) database.JobFilter { return database.JobFilter{BatchID: id} })
+}
+
+func cancelByPathID(w http.ResponseWriter, r *http.Request, filter func(id int64) database.JobFilter) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	canceled, err := cancelJobs(filter(id))
+	writeCanceled(w, canceled, err)
+}
+
+// writeCanceled responds with the IDs of canceled jobs.
+func writeCanceled(w http.ResponseWriter, canceled []int64, err error) {
+	if err != nil {
+		log.Printf("Failed to cancel jobs: %v\n", err)
+		http.Error(w, "Failed to cancel", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if len(canceled) == 0 {
+		w.Write([]byte(`{"message": "Task not running or already completed", "job_ids": []}`))
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": fmt.Sprintf("Canceled %d job(s)", len(canceled)),
+		"job_ids": canceled,
+	})
+}
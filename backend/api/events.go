@@ -0,0 +1,263 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+const (
+	// feedInterval is how often the job states and phases recorded by the
+	// workers are read.
+	feedInterval = 500 * time.Millisecond
+	// feedLag is how far back state changes are read again: a transaction
+	// may commit an event after one with a higher ID was already read.
+	feedLag = 100
+	// streamHeartbeat keeps idle progress streams from being closed by proxies.
+	streamHeartbeat = 15 * time.Second
+	// subscriberBuffer is how many events a slow client may fall behind
+	// before events are dropped for it.
+	subscriberBuffer = 256
+)
+
+// progressHub fans progress events out to the connected stream clients.
+var progressHub = newEventHub()
+
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan models.ProgressEvent]eventFilter
+}
+
+// eventFilter selects the events of one job or batch; zero matches all.
+type eventFilter struct {
+	jobID   int64
+	batchID int64
+}
+
+func (f eventFilter) match(ev models.ProgressEvent) bool {
+	return (f.jobID == 0 || ev.JobID == f.jobID) && (f.batchID == 0 || ev.BatchID == f.batchID)
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[chan models.ProgressEvent]eventFilter)}
+}
+
+func (h *eventHub) Subscribe(f eventFilter) chan models.ProgressEvent {
+	ch := make(chan models.ProgressEvent, subscriberBuffer)
+	h.mu.Lock()
+	h.subscribers[ch] = f
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *eventHub) Unsubscribe(ch chan models.ProgressEvent) {
+	h.mu.Lock()
+	delete(h.subscribers, ch)
+	h.mu.Unlock()
+}
+
+// Publish sends ev to every matching subscriber without blocking; clients
+// that do not keep up miss events.
+func (h *eventHub) Publish(ev models.ProgressEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch, f := range h.subscribers {
+		if !f.match(ev) {
+			continue
+		}
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Active reports whether any client is subscribed.
+func (h *eventHub) Active() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers) > 0
+}
+
+// StartProgressFeed publishes the job state changes and phases that the
+// workers, in this process or any other, record in the database.
+func StartProgressFeed() {
+	go feedProgress(context.Background())
+}
+
+// feedProgress polls the database while clients are subscribed and
+// publishes what changed.
+func feedProgress(ctx context.Context) {
+	var (
+		lastID int64
+		seen   = make(map[int64]bool)   // state changes published, by ID
+		phases = make(map[int64]string) // last phase published, by job
+	)
+	ticker := time.NewTicker(feedInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !progressHub.Active() {
+			lastID = 0
+			continue
+		}
+		if lastID == 0 {
+			// New clients get a snapshot, so start from the current event.
+			id, err := db.LastStateChangeID()
+			if err != nil {
+				log.Printf("Failed to read job events: %v\n", err)
+				continue
+			}
+			lastID, seen = id, make(map[int64]bool)
+		}
+
+		changes, err := db.StateChangesSince(max(lastID-feedLag, 0))
+		if err != nil {
+			log.Printf("Failed to read job events: %v\n", err)
+			continue
+		}
+		for _, change := range changes {
+			if change.ID <= lastID-feedLag || seen[change.ID] {
+				continue
+			}
+			seen[change.ID] = true
+			progressHub.Publish(change.Event)
+			lastID = max(lastID, change.ID)
+		}
+		for id := range seen {
+			if id <= lastID-feedLag {
+				delete(seen, id)
+			}
+		}
+
+		running, err := db.RunningJobs()
+		if err != nil {
+			log.Printf("Failed to read running jobs: %v\n", err)
+			continue
+		}
+		now := time.Now()
+		current := make(map[int64]string, len(running))
+		for _, job := range running {
+			if job.Phase == nil {
+				continue
+			}
+			key := fmt.Sprintf("%s %s %d/%d", job.Phase.Phase, job.Phase.URL, job.Phase.Done, job.Phase.Total)
+			current[job.ID] = key
+			if phases[job.ID] == key {
+				continue
+			}
+			progressHub.Publish(models.ProgressEvent{
+				Type:    "phase",
+				JobID:   job.ID,
+				BatchID: job.BatchID,
+				URL:     job.Phase.URL,
+				Phase:   job.Phase.Phase,
+				Done:    job.Phase.Done,
+				Total:   job.Phase.Total,
+				Time:    now,
+			})
+		}
+		phases = current
+	}
+}
+
+// stateSnapshot returns a state event for every job of statuses that
+// matches filter, ordered by job ID.
+func stateSnapshot(statuses map[int64]jobStatus, filter eventFilter) []models.ProgressEvent {
+	var snapshot []models.ProgressEvent
+	for _, status := range statuses {
+		ev := models.ProgressEvent{
+			Type:    "state",
+			JobID:   status.ID,
+			BatchID: status.BatchID,
+			URL:     status.URL,
+			State:   status.State,
+			Phase:   status.Phase,
+			Done:    status.Done,
+			Total:   status.Total,
+			Time:    status.UpdatedAt,
+		}
+		if filter.match(ev) {
+			snapshot = append(snapshot, ev)
+		}
+	}
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].JobID < snapshot[j].JobID })
+	return snapshot
+}
+
+// EventsHandler streams progress events as Server-Sent Events. ?job_id= or
+// ?batch_id= limit the stream to one job or batch. The stream starts with a
+// state event for every known matching job, in job ID order.
+func EventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var filter eventFilter
+	for key, dst := range map[string]*int64{"job_id": &filter.jobID, "batch_id": &filter.batchID} {
+		if v := r.URL.Query().Get(key); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid "+key, http.StatusBadRequest)
+				return
+			}
+			*dst = id
+		}
+	}
+
+	events := progressHub.Subscribe(filter)
+	defer progressHub.Unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	statuses, err := queueStatus()
+	if err != nil {
+		log.Printf("Failed to load jobs: %v\n", err)
+	}
+	for _, ev := range stateSnapshot(statuses, filter) {
+		writeEvent(w, ev)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case ev := <-events:
+			writeEvent(w, ev)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes ev as an SSE event named after its type.
+func writeEvent(w http.ResponseWriter, ev models.ProgressEvent) {
+	data, _ := json.Marshal(ev)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+}
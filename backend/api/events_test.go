@@ -0,0 +1,75 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"scrawling_dashboard/backend/models"
+)
+
+func TestEventHubFilter(t *testing.T) {
+	hub := newEventHub()
+	all := hub.Subscribe(eventFilter{})
+	job := hub.Subscribe(eventFilter{jobID: 2})
+	batch := hub.Subscribe(eventFilter{batchID: 7})
+	defer hub.Unsubscribe(all)
+	defer hub.Unsubscribe(job)
+	defer hub.Unsubscribe(batch)
+
+	hub.Publish(models.ProgressEvent{Type: "state", JobID: 1, BatchID: 7})
+	hub.Publish(models.ProgressEvent{Type: "state", JobID: 2})
+
+	for name, want := range map[string]struct {
+		ch   chan models.ProgressEvent
+		jobs []int64
+	}{
+		"all":   {all, []int64{1, 2}},
+		"job":   {job, []int64{2}},
+		"batch": {batch, []int64{1}},
+	} {
+		var got []int64
+		for len(want.ch) > 0 {
+			got = append(got, (<-want.ch).JobID)
+		}
+		if !reflect.DeepEqual(got, want.jobs) {
+			t.Errorf("%s subscriber got jobs %v, want %v", name, got, want.jobs)
+		}
+	}
+}
+
+func TestEventHubSlowSubscriber(t *testing.T) {
+	hub := newEventHub()
+	ch := hub.Subscribe(eventFilter{})
+	defer hub.Unsubscribe(ch)
+
+	// Publish must not block on a client that stopped reading.
+	for i := 0; i < subscriberBuffer+10; i++ {
+		hub.Publish(models.ProgressEvent{Type: "phase", JobID: int64(i)})
+	}
+	if len(ch) != subscriberBuffer {
+		t.Errorf("%d events buffered, want %d and the rest dropped", len(ch), subscriberBuffer)
+	}
+}
+
+func TestStateSnapshotOrder(t *testing.T) {
+	statuses := make(map[int64]jobStatus)
+	for _, id := range []int64{9, 3, 12, 1, 7, 4} {
+		statuses[id] = jobStatus{ID: id, BatchID: id % 2}
+	}
+
+	var got []int64
+	for _, ev := range stateSnapshot(statuses, eventFilter{}) {
+		got = append(got, ev.JobID)
+	}
+	if want := []int64{1, 3, 4, 7, 9, 12}; !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshot jobs = %v, want %v", got, want)
+	}
+
+	got = nil
+	for _, ev := range stateSnapshot(statuses, eventFilter{batchID: 1}) {
+		got = append(got, ev.JobID)
+	}
+	if want := []int64{1, 3, 7, 9}; !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshot of batch 1 = %v, want %v", got, want)
+	}
+}
@@ -1,173 +1,104 @@
 package api
 
 import (
-	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
-	"sync"
+	"strconv"
 	"time"
 
 	"scrawling_dashboard/backend/crawler"
 	"scrawling_dashboard/backend/database"
 	"scrawling_dashboard/backend/models"
+	"scrawling_dashboard/backend/worker"
 )
 
-var (
-	statusMap    = make(map[string]string)
-	cancelMap    = make(map[string]context.CancelFunc)
-	statusMutex  sync.Mutex
-	crawlQueue   = make([]string, 0)
-	crawlRunning = false
-)
+// db holds the jobs and results the handlers serve.
+var db database.Store
 
-func enqueueCrawl(url string) {
-	statusMutex.Lock()
-	defer statusMutex.Unlock()
+// UseStore sets the store of the handlers, the workers and the scheduler. It
+// must be called before they start.
+func UseStore(store database.Store) {
+	db = store
+}
 
-	if statusMap[url] == "queued" || statusMap[url] == "running" {
-		return
-	}
-	statusMap[url] = "queued"
-	crawlQueue = append(crawlQueue, url)
+// jobStatus is the state of a job reported by the progress API.
+type jobStatus struct {
+	ID        int64     `json:"id"`
+	BatchID   int64     `json:"batch_id,omitempty"`
+	URL       string    `json:"url"`
+	State     string    `json:"state"`
+	UpdatedAt time.Time `json:"updated_at"`
+	WorkerID  string    `json:"worker_id,omitempty"`
+
+	// Phase is what a running job is doing; Done and Total count checked
+	// links while checking links.
+	Phase string `json:"phase,omitempty"`
+	Done  int    `json:"done,omitempty"`
+	Total int    `json:"total,omitempty"`
+
+	// Position (1 = next) and ETASeconds, the estimated wait until the job
+	// starts, are set for queued jobs.
+	Position   int     `json:"position,omitempty"`
+	ETASeconds float64 `json:"eta_seconds,omitempty"`
+}
 
-	if !crawlRunning {
-		crawlRunning = true
-		go processQueue()
+func newJobStatus(job models.Job) jobStatus {
+	status := jobStatus{
+		ID:        job.ID,
+		BatchID:   job.BatchID,
+		URL:       job.URL,
+		State:     job.State,
+		UpdatedAt: job.UpdatedAt,
+		WorkerID:  job.WorkerID,
+	}
+	if job.Phase != nil && job.State == models.JobRunning {
+		status.Phase, status.Done, status.Total = job.Phase.Phase, job.Phase.Done, job.Phase.Total
 	}
+	return status
 }
 
-func processQueue() {
-	for {
-		statusMutex.Lock()
-		if len(crawlQueue) == 0 {
-			crawlRunning = false
-			statusMutex.Unlock()
-			return
-		}
-
-		url := crawlQueue[0]
-		crawlQueue = crawlQueue[1:]
-		statusMap[url] = "running"
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-		cancelMap[url] = cancel
-		statusMutex.Unlock()
-
-		result, err := crawler.CrawlURLWithContext(ctx, url)
-
-		statusMutex.Lock()
-		delete(cancelMap, url)
-
-		if err != nil || result == nil {
-			statusMap[url] = "error"
-			errMsg := "Unknown error"
-			if err != nil {
-				errMsg = err.Error()
-			}
-			_, dbErr := database.DB.Exec(`
-				INSERT INTO urls (url, status, error_message, created_at)
-				VALUES (?, 'error', ?, ?)`,
-				url, errMsg, time.Now())
-			if dbErr != nil {
-				log.Printf("DB insert error (error case): %v\n", dbErr)
-			} else {
-				log.Printf("Crawling failed for %s: %s\n", url, errMsg)
-			}
-		} else {
-			headingsJSON, _ := json.Marshal(result.Headings)
-			brokenLinksJSON, _ := json.Marshal(result.BrokenLinks)
-			statusMap[url] = "done"
-			_, dbErr := database.DB.Exec(`
-				INSERT INTO urls
-				(url, html_version, title, headings, internal_links, external_links,
-				 broken_links, has_login_form, status, created_at)
-				VALUES (?, ?, ?, ?, ?, ?, ?, ?, 'done', ?)`,
-				url, result.HTMLVersion, result.Title, headingsJSON, result.InternalLinks,
-				result.ExternalLinks, brokenLinksJSON, result.HasLoginForm, time.Now())
-			if dbErr != nil {
-				log.Printf("DB insert error (success case): %v\n", dbErr)
-			} else {
-				log.Printf("Crawling completed for %s\n", url)
-			}
-		}
+// finishedJobTTL is how long finished jobs are reported by the progress API.
+const finishedJobTTL = time.Hour
 
-		statusMutex.Unlock()
+// enqueueCrawl creates a job for url and queues it. Every call is a new job,
+// even for a URL that is already queued.
+func enqueueCrawl(url string, opts models.CrawlOptions) (int64, error) {
+	id, err := db.CreateJob(url, opts, 0)
+	if err != nil {
+		return 0, err
 	}
+	notifyWorkers()
+	return id, nil
 }
 
 func handleGetCrawled(w http.ResponseWriter, r *http.Request) {
-	rows, err := database.DB.Query(`
-		SELECT id, url, html_version, title, headings, internal_links, external_links,
-		       broken_links, has_login_form, status, error_message, created_at
-		FROM urls ORDER BY created_at DESC`)
-	if err != nil {
-		http.Error(w, "Query failed", http.StatusInternalServerError)
-		log.Println("Query failed:", err)
-		return
-	}
-	defer rows.Close()
-
-	var results []models.Result
-
-	for rows.Next() {
-		var (
-			id               int
-			url              string
-			htmlVersionNS    sql.NullString
-			titleNS          sql.NullString
-			headingsJSON     []byte
-			internal         int
-			external         int
-			brokenLinksJSON  []byte
-			hasLogin         bool
-			status           string
-			errorMessageNS   sql.NullString
-			createdAtStr     string
-			headings         map[string]int
-			brokenLinks      []models.BrokenLink
-		)
-
-		if err := rows.Scan(&id, &url, &htmlVersionNS, &titleNS, &headingsJSON, &internal,
-			&external, &brokenLinksJSON, &hasLogin, &status, &errorMessageNS, &createdAtStr); err != nil {
-			log.Printf("Row scan failed: %v\n", err)
-			continue
-		}
-
-		if err := json.Unmarshal(headingsJSON, &headings); err != nil {
-			log.Printf("Unmarshal headings failed: %v\n", err)
-			headings = map[string]int{}
-		}
-		if err := json.Unmarshal(brokenLinksJSON, &brokenLinks); err != nil {
-			log.Printf("Unmarshal broken links failed: %v\n", err)
-			brokenLinks = []models.BrokenLink{}
+	var filter database.ResultFilter
+	if parent := r.URL.Query().Get("parent_id"); parent != "" {
+		parentID, err := strconv.ParseInt(parent, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid parent_id", http.StatusBadRequest)
+			return
 		}
-
-		createdAt, err := time.Parse("2006-01-02 15:04:05", createdAtStr)
+		filter.SiteID = parentID
+	}
+	if job := r.URL.Query().Get("job_id"); job != "" {
+		jobID, err := strconv.ParseInt(job, 10, 64)
 		if err != nil {
-			log.Printf("Parse created_at failed: %v\n", err)
-			continue
+			http.Error(w, "Invalid job_id", http.StatusBadRequest)
+			return
 		}
-
-		results = append(results, models.Result{
-			ID:            id,
-			URL:           url,
-			HTMLVersion:   htmlVersionNS.String,
-			Title:         titleNS.String,
-			Headings:      headings,
-			InternalLinks: internal,
-			ExternalLinks: external,
-			BrokenLinks:   brokenLinks,
-			HasLoginForm:  hasLogin,
-			Status:        status,
-			ErrorMessage:  errorMessageNS.String,
-			CreatedAt:     createdAt,
-		})
+		filter.JobID = jobID
 	}
 
-	if results == nil {
-		results = []models.Result{}
+	results, err := db.ListResults(filter)
+	if err != nil {
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		log.Println("Query failed:", err)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -190,68 +121,217 @@ func URLHandler(w http.ResponseWriter, r *http.Request) {
 
 func CrawlHandler(w http.ResponseWriter, r *http.Request) {
 	var payload models.RequestPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.URL == "" {
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil ||
+		(payload.URL == "" && payload.Sitemap == "") {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
-	enqueueCrawl(payload.URL)
+	// Store the URL in the form batches use, so that the runs of a page
+	// match however it was submitted.
+	if payload.URL != "" {
+		url, err := crawler.NormalizeURL(payload.URL)
+		if err != nil {
+			http.Error(w, "Invalid URL: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		payload.URL = url
+	}
+	if err := validateOptions(&payload.CrawlOptions); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if payload.Project == "" {
+		payload.Project = sessionUser(r)
+	}
+	if payload.Sitemap != "" || payload.DiscoverSitemap {
+		handleSitemapCrawl(w, r, payload)
+		return
+	}
+	id, err := enqueueCrawl(payload.URL, payload.CrawlOptions)
+	if err != nil {
+		log.Printf("Failed to create crawl job: %v\n", err)
+		http.Error(w, "Failed to queue crawl", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
-	w.Write([]byte(`{"message": "Added to crawl queue"}`))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Added to crawl queue",
+		"job_id":  id,
+	})
+}
+
+// validateOptions checks the crawl options of a request and fills in the
+// defaults of the crawl mode.
+func validateOptions(opts *models.CrawlOptions) error {
+	switch opts.Mode {
+	case "", "page":
+		opts.Mode = "page"
+	case "site":
+		site, err := crawler.NormalizeSiteOptions(opts.Site)
+		if err != nil {
+			return err
+		}
+		opts.Site = site
+	default:
+		return errors.New("Invalid mode")
+	}
+	switch opts.Priority {
+	case "":
+		opts.Priority = models.PriorityNormal
+	case models.PriorityHigh, models.PriorityNormal, models.PriorityLow:
+	default:
+		return errors.New("Invalid priority")
+	}
+	_, err := crawler.NewFetcher(opts.Fetcher, nil)
+	return err
 }
 
+// StopHandler cancels the job given by job_id, every unfinished job of a
+// batch_id or, for requests that only give a url, every unfinished job of
+// that URL.
 func StopHandler(w http.ResponseWriter, r *http.Request) {
 	var payload models.RequestPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.URL == "" {
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil ||
+		(payload.JobID == 0 && payload.BatchID == 0 && payload.URL == "") {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
+	// Jobs are stored under the normalized URL.
+	if payload.JobID == 0 && payload.BatchID == 0 {
+		url, err := crawler.NormalizeURL(payload.URL)
+		if err != nil {
+			http.Error(w, "Invalid URL: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		payload.URL = url
+	}
 
-	statusMutex.Lock()
-	status := statusMap[payload.URL]
-	cancel, exists := cancelMap[payload.URL]
-	statusMutex.Unlock()
-
-	if exists {
-		cancel() 
-
-		// If status is running, mark as "error" and remove from cancelMap
-		if status == "running" {
-			statusMutex.Lock()
-			statusMap[payload.URL] = "error"
-			delete(cancelMap, payload.URL)
-			statusMutex.Unlock()
-
-			// Update the database asynchronously
-			go func() {
-				if err := database.UpdateCrawlStatus(payload.URL, "error"); err != nil {
-					log.Printf("❌ Failed to update DB: %v", err)
-				}
-			}()
+	var canceled []int64
+	var err error
+	switch {
+	case payload.JobID != 0:
+		canceled, err = cancelJobs(database.JobFilter{ID: payload.JobID})
+	case payload.BatchID != 0:
+		canceled, err = cancelJobs(database.JobFilter{BatchID: payload.BatchID})
+	default:
+		canceled, err = cancelJobs(database.JobFilter{URL: payload.URL})
+	}
+	writeCanceled(w, canceled, err)
+}
 
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(`{"message": "Running task stopped and marked as error"}`))
+// ProgressHandler returns the state of the latest job of every URL. With
+// ?job_id= it returns that job, and with ?detail=true every job by ID along
+// with the workers, whether they are paused, and the per-host throttle state
+// of each worker, globally and for crawls with their own rate limit.
+func ProgressHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if job := r.URL.Query().Get("job_id"); job != "" {
+		id, err := strconv.ParseInt(job, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid job_id", http.StatusBadRequest)
 			return
 		}
+		stored, err := db.GetJob(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("Failed to load job %d: %v\n", id, err)
+			http.Error(w, "Query failed", http.StatusInternalServerError)
+			return
+		}
+		status := newJobStatus(stored)
+		if stored.State == models.JobQueued {
+			// Only the whole queue tells the job's position. A job that
+			// was queued a moment ago is still active, so one missing
+			// from the queue has been purged since.
+			if statuses, err := queueStatus(); err == nil {
+				queued, ok := statuses[id]
+				if !ok {
+					http.Error(w, "Job not found", http.StatusNotFound)
+					return
+				}
+				status = queued
+			}
+		}
+		json.NewEncoder(w).Encode(status)
+		return
+	}
 
-		statusMutex.Lock()
-		delete(cancelMap, payload.URL)
-		statusMutex.Unlock()
-
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"message": "Queued task stopped without changing status"}`))
+	statuses, err := queueStatus()
+	if err != nil {
+		log.Printf("Failed to load jobs: %v\n", err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	if detail, _ := strconv.ParseBool(r.URL.Query().Get("detail")); !detail {
+		latest := make(map[string]jobStatus)
+		for _, status := range statuses {
+			if prev, ok := latest[status.URL]; !ok || status.ID > prev.ID {
+				latest[status.URL] = status
+			}
+		}
+		byURL := make(map[string]string, len(latest))
+		for url, status := range latest {
+			byURL[url] = status.State
+		}
+		json.NewEncoder(w).Encode(byURL)
 		return
 	}
 
-	// Nếu không tồn tại trong cancelMap
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"message": "Task not running or already completed"}`))
+	pool, err := poolState()
+	if err != nil {
+		log.Printf("Failed to load workers: %v\n", err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	throttle := make(map[string]worker.Throttle)
+	for _, instance := range pool.Workers {
+		var stats worker.Stats
+		if json.Unmarshal(instance.Stats, &stats) == nil {
+			throttle[instance.ID] = stats.Throttle
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobs":     statuses,
+		"workers":  pool,
+		"throttle": throttle,
+	})
 }
 
-func ProgressHandler(w http.ResponseWriter, r *http.Request) {
-	statusMutex.Lock()
-	defer statusMutex.Unlock()
+// LinkCacheHandler reports link cache statistics, summed over the running
+// workers.
+func LinkCacheHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	workers, err := db.ListWorkers(workerTimeout)
+	if err != nil {
+		log.Printf("Failed to load workers: %v\n", err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	var total crawler.LinkCacheStats
+	for _, instance := range workers {
+		var stats worker.Stats
+		if json.Unmarshal(instance.Stats, &stats) != nil {
+			continue
+		}
+		cache := stats.LinkCache
+		total.Entries += cache.Entries
+		total.Hits += cache.Hits
+		total.StoreHits += cache.StoreHits
+		total.Misses += cache.Misses
+		total.TTL = cache.TTL
+		total.Store = total.Store || cache.Store
+	}
+	if lookups := total.Hits + total.StoreHits + total.Misses; lookups > 0 {
+		total.HitRate = float64(total.Hits+total.StoreHits) / float64(lookups)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(statusMap)
+	json.NewEncoder(w).Encode(total)
 }
 
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
@@ -275,6 +355,7 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	session, _ := GetSession(r)
 	session.Values["authenticated"] = true
+	session.Values["username"] = creds.Username
 	session.Save(r, w)
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"message": "Login successful"}`))
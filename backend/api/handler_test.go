@@ -0,0 +1,263 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"scrawling_dashboard/backend/database"
+	"scrawling_dashboard/backend/models"
+)
+
+// useTestStore points the handlers at a migrated in-memory SQLite store.
+func useTestStore(t *testing.T) *database.SQLStore {
+	t.Helper()
+	store, err := database.OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	UseStore(store)
+	return store
+}
+
+func postCrawl(body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	CrawlHandler(rec, httptest.NewRequest(http.MethodPost, "/api/crawl", strings.NewReader(body)))
+	return rec
+}
+
+func TestCrawlHandlerNormalizesURL(t *testing.T) {
+	store := useTestStore(t)
+
+	rec := postCrawl(`{"url": " Example.com "}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		JobID int64 `json:"job_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	job, err := store.GetJob(resp.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.URL != "https://example.com/" {
+		t.Errorf("job URL = %q, want https://example.com/", job.URL)
+	}
+
+	if rec := postCrawl(`{"url": "ftp://example.com/"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("unsupported scheme: status %d, want 400", rec.Code)
+	}
+}
+
+func TestProgressHandlerUnknownJob(t *testing.T) {
+	useTestStore(t)
+	rec := httptest.NewRecorder()
+	ProgressHandler(rec, httptest.NewRequest(http.MethodGet, "/api/progress?job_id=42", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status %d, want 404", rec.Code)
+	}
+}
+
+func TestStopHandlerByURL(t *testing.T) {
+	store := useTestStore(t)
+	target, err := store.CreateJob("https://example.com/", models.CrawlOptions{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := store.CreateJob("https://other.example/", models.CrawlOptions{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		StopHandler(rec, httptest.NewRequest(http.MethodPost, "/api/stop", strings.NewReader(body)))
+		return rec
+	}
+	// The URL is matched in the form jobs are stored in.
+	rec := stop(`{"url": "Example.com"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		JobIDs []int64 `json:"job_ids"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.JobIDs) != 1 || resp.JobIDs[0] != target {
+		t.Errorf("canceled %v, want job %d", resp.JobIDs, target)
+	}
+	if job, _ := store.GetJob(other); job.State != models.JobQueued {
+		t.Errorf("job of another URL is %s", job.State)
+	}
+
+	if rec := stop(`{"url": "ftp://example.com/"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("unsupported scheme: status %d, want 400", rec.Code)
+	}
+}
+
+func TestSitemapCrawl(t *testing.T) {
+	store := useTestStore(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sitemap.xml" {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `<?xml version="1.0"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>https://A.example/</loc></url>
+<url><loc>https://a.example</loc></url>
+<url><loc>https://a.example/b</loc></url>
+</urlset>`)
+	}))
+	defer srv.Close()
+
+	rec := postCrawl(`{"sitemap": "` + srv.URL + `/sitemap.xml"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		BatchID int64   `json:"batch_id"`
+		JobIDs  []int64 `json:"job_ids"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	// The first two entries normalize to the same URL.
+	if len(resp.JobIDs) != 2 {
+		t.Fatalf("queued %d jobs, want 2: %s", len(resp.JobIDs), rec.Body)
+	}
+	batch, err := store.GetBatch(resp.BatchID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Source != "sitemap" || batch.Accepted != 2 {
+		t.Errorf("batch = %+v", batch)
+	}
+	if jobs, _ := store.BatchJobs(resp.BatchID); len(jobs) != 2 || jobs[0].URL != "https://a.example/" {
+		t.Errorf("batch jobs = %+v", jobs)
+	}
+
+	rec = postCrawl(`{"sitemap": "` + srv.URL + `/broken.xml"}`)
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("unreadable sitemap: status %d, want 502", rec.Code)
+	}
+}
+
+func TestSitemapCrawlCapped(t *testing.T) {
+	store := useTestStore(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+		for i := 0; i < maxBatchURLs+5; i++ {
+			fmt.Fprintf(w, "<url><loc>https://a.example/%d</loc></url>\n", i)
+		}
+		fmt.Fprint(w, `</urlset>`)
+	}))
+	defer srv.Close()
+
+	rec := postCrawl(`{"sitemap": "` + srv.URL + `/sitemap.xml"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Message  string `json:"message"`
+		BatchID  int64  `json:"batch_id"`
+		Enqueued int    `json:"enqueued"`
+		Skipped  int    `json:"skipped"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	// A sitemap batch is capped like a submitted one.
+	if resp.Enqueued != maxBatchURLs || resp.Skipped != 5 || !strings.Contains(resp.Message, "skipped") {
+		t.Errorf("response = %+v, want %d enqueued and 5 skipped", resp, maxBatchURLs)
+	}
+	if batch, err := store.GetBatch(resp.BatchID); err != nil || batch.Accepted != maxBatchURLs || batch.Rejected != 5 {
+		t.Errorf("batch = %+v, %v", batch, err)
+	}
+}
+
+func TestHistoryAndDiff(t *testing.T) {
+	store := useTestStore(t)
+	url := "https://h.example/"
+	insert := func(result models.CrawlResult) int64 {
+		t.Helper()
+		result.URL = url
+		id, err := store.InsertCrawlResult(&result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	first := insert(models.CrawlResult{
+		Title:       "A",
+		Headings:    map[string]int{"h1": 1},
+		BrokenLinks: []models.BrokenLink{{URL: url + "x", StatusCode: 404}},
+		Links:       []models.PageLink{{URL: url + "x"}, {URL: url + "y"}},
+	})
+	second := insert(models.CrawlResult{
+		Title:       "B",
+		Headings:    map[string]int{"h1": 2},
+		BrokenLinks: []models.BrokenLink{{URL: url + "z", StatusCode: 500}},
+		Links:       []models.PageLink{{URL: url + "y"}, {URL: url + "z"}},
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/urls/history", HistoryHandler)
+	mux.HandleFunc("/api/urls/diff", DiffHandler)
+	get := func(path string, v interface{}) int {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if v != nil && rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code
+	}
+
+	var history []models.HistoryEntry
+	if code := get("/api/urls/history?url="+url, &history); code != http.StatusOK {
+		t.Fatalf("history: status %d", code)
+	}
+	if len(history) != 2 || int64(history[0].ResultID) != second || len(history[0].Changes) == 0 || len(history[1].Changes) != 0 {
+		t.Errorf("history = %+v", history)
+	}
+
+	// The URL is matched in its stored form, however it is written.
+	history = nil
+	if code := get("/api/urls/history?url=H.example", &history); code != http.StatusOK || len(history) != 2 {
+		t.Errorf("history of the un-normalized URL: status %d, %d runs; want 2", code, len(history))
+	}
+
+	var diff models.ResultDiff
+	if code := get(fmt.Sprintf("/api/urls/diff?from=%d&to=%d", first, second), &diff); code != http.StatusOK {
+		t.Fatalf("diff: status %d", code)
+	}
+	if !diff.Changed || diff.Title == nil || diff.Title.To != "B" || diff.Headings["h1"] != 1 || !diff.LinksCompared ||
+		len(diff.LinksAdded) != 1 || len(diff.LinksRemoved) != 1 || len(diff.BrokenNew) != 1 || len(diff.BrokenFixed) != 1 {
+		t.Errorf("diff = %+v", diff)
+	}
+
+	for path, want := range map[string]int{
+		"/api/urls/history":                     http.StatusBadRequest,
+		"/api/urls/diff?from=999&to=1":          http.StatusNotFound,
+		"/api/urls/diff?url=https://o.example":  http.StatusNotFound,
+		"/api/urls/diff?url=HTTPS://h.example":  http.StatusOK,
+		"/api/urls/history?url=ftp://h.example": http.StatusBadRequest,
+		"/api/urls/diff?url=ftp://h.example":    http.StatusBadRequest,
+	} {
+		if code := get(path, nil); code != want {
+			t.Errorf("%s: status %d, want %d", path, code, want)
+		}
+	}
+}
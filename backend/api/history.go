@@ -0,0 +1,292 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"scrawling_dashboard/backend/crawler"
+	"scrawling_dashboard/backend/database"
+	"scrawling_dashboard/backend/models"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+// HistoryHandler returns the runs of ?url=, newest first, each with what
+// changed since the run before it. ?limit= caps the number of runs.
+func HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.URL.Query().Get("url") == "" {
+		http.Error(w, "url is required", http.StatusBadRequest)
+		return
+	}
+	url, ok := normalizeQueryURL(w, r.URL.Query().Get("url"))
+	if !ok {
+		return
+	}
+	limit := defaultHistoryLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxHistoryLimit)
+	}
+
+	// One more run than returned tells what changed in the oldest one.
+	results, err := db.ListResults(database.ResultFilter{URL: url, Limit: limit + 1})
+	if err != nil {
+		log.Printf("Failed to load history of %s: %v\n", url, err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	entries := []models.HistoryEntry{}
+	for i := 0; i < len(results) && i < limit; i++ {
+		entry := newHistoryEntry(results[i])
+		if i+1 < len(results) {
+			entry.Changes = historyChanges(results[i+1], results[i])
+		}
+		entries = append(entries, entry)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// DiffHandler compares two runs of the same URL: the results ?from= and ?to=,
+// or the latest two runs of ?url=.
+func DiffHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	var from, to models.Result
+	if query.Get("url") != "" && query.Get("from") == "" && query.Get("to") == "" {
+		url, ok := normalizeQueryURL(w, query.Get("url"))
+		if !ok {
+			return
+		}
+		results, err := db.ListResults(database.ResultFilter{URL: url, Limit: 2})
+		if err != nil {
+			log.Printf("Failed to load history of %s: %v\n", url, err)
+			http.Error(w, "Query failed", http.StatusInternalServerError)
+			return
+		}
+		if len(results) < 2 {
+			http.Error(w, "URL has fewer than two runs", http.StatusNotFound)
+			return
+		}
+		from, to = results[1], results[0]
+	} else {
+		var ok bool
+		if from, ok = loadResult(w, query.Get("from"), "from"); !ok {
+			return
+		}
+		if to, ok = loadResult(w, query.Get("to"), "to"); !ok {
+			return
+		}
+		if from.URL != to.URL {
+			http.Error(w, "Results are of different URLs", http.StatusBadRequest)
+			return
+		}
+	}
+
+	fromLinks, err := db.ResultLinks(int64(from.ID))
+	if err != nil {
+		log.Printf("Failed to load links of result %d: %v\n", from.ID, err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	toLinks, err := db.ResultLinks(int64(to.ID))
+	if err != nil {
+		log.Printf("Failed to load links of result %d: %v\n", to.ID, err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diffResults(from, to, fromLinks, toLinks))
+}
+
+// normalizeQueryURL returns the stored form of a URL given as a query
+// parameter, writing an error response if it is invalid.
+func normalizeQueryURL(w http.ResponseWriter, raw string) (string, bool) {
+	url, err := crawler.NormalizeURL(raw)
+	if err != nil {
+		http.Error(w, "Invalid URL: "+err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return url, true
+}
+
+// loadResult reads the result whose ID is the query parameter name, writing
+// an error response if it cannot.
+func loadResult(w http.ResponseWriter, value, name string) (models.Result, bool) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid "+name, http.StatusBadRequest)
+		return models.Result{}, false
+	}
+	result, err := db.GetResult(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Result not found", http.StatusNotFound)
+		return models.Result{}, false
+	} else if err != nil {
+		log.Printf("Failed to load result %d: %v\n", id, err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return models.Result{}, false
+	}
+	return result, true
+}
+
+func newResultRun(result models.Result) models.ResultRun {
+	return models.ResultRun{
+		ResultID:  result.ID,
+		JobID:     result.JobID,
+		CrawledAt: result.CreatedAt,
+		Status:    result.Status,
+	}
+}
+
+func newHistoryEntry(result models.Result) models.HistoryEntry {
+	return models.HistoryEntry{
+		ResultRun:     newResultRun(result),
+		Title:         result.Title,
+		HTMLVersion:   result.HTMLVersion,
+		HasLoginForm:  result.HasLoginForm,
+		InternalLinks: result.InternalLinks,
+		ExternalLinks: result.ExternalLinks,
+		BrokenLinks:   len(result.BrokenLinks),
+		ErrorMessage:  result.ErrorMessage,
+	}
+}
+
+// historyChanges names what differs between a run and the one before it.
+func historyChanges(prev, cur models.Result) []string {
+	diff := diffResults(prev, cur, nil, nil)
+	var changes []string
+	if diff.Status != nil {
+		changes = append(changes, "status")
+	}
+	if diff.Title != nil {
+		changes = append(changes, "title")
+	}
+	if diff.HTMLVersion != nil {
+		changes = append(changes, "html_version")
+	}
+	if diff.HasLoginForm != nil {
+		changes = append(changes, "has_login_form")
+	}
+	if len(diff.Headings) > 0 {
+		changes = append(changes, "headings")
+	}
+	if prev.InternalLinks != cur.InternalLinks || prev.ExternalLinks != cur.ExternalLinks {
+		changes = append(changes, "link_counts")
+	}
+	if len(diff.BrokenNew) > 0 {
+		changes = append(changes, "broken_new")
+	}
+	if len(diff.BrokenFixed) > 0 {
+		changes = append(changes, "broken_fixed")
+	}
+	return changes
+}
+
+// diffResults compares two runs of a URL. The links of each run are only
+// compared if both are given and stored.
+func diffResults(from, to models.Result, fromLinks, toLinks []models.PageLink) models.ResultDiff {
+	diff := models.ResultDiff{
+		URL:  to.URL,
+		From: newResultRun(from),
+		To:   newResultRun(to),
+	}
+	if from.Status != to.Status {
+		diff.Status = &models.StringChange{From: from.Status, To: to.Status}
+	}
+	if from.Title != to.Title {
+		diff.Title = &models.StringChange{From: from.Title, To: to.Title}
+	}
+	if from.HTMLVersion != to.HTMLVersion {
+		diff.HTMLVersion = &models.StringChange{From: from.HTMLVersion, To: to.HTMLVersion}
+	}
+	if from.HasLoginForm != to.HasLoginForm {
+		diff.HasLoginForm = &models.BoolChange{From: from.HasLoginForm, To: to.HasLoginForm}
+	}
+
+	for tag, count := range to.Headings {
+		if delta := count - from.Headings[tag]; delta != 0 {
+			if diff.Headings == nil {
+				diff.Headings = map[string]int{}
+			}
+			diff.Headings[tag] = delta
+		}
+	}
+	for tag, count := range from.Headings {
+		if _, ok := to.Headings[tag]; !ok && count != 0 {
+			if diff.Headings == nil {
+				diff.Headings = map[string]int{}
+			}
+			diff.Headings[tag] = -count
+		}
+	}
+
+	if linksStored(from, fromLinks) && linksStored(to, toLinks) {
+		diff.LinksCompared = true
+		diff.LinksAdded = missingLinks(toLinks, fromLinks)
+		diff.LinksRemoved = missingLinks(fromLinks, toLinks)
+	}
+	diff.BrokenNew = missingBroken(to.BrokenLinks, from.BrokenLinks)
+	diff.BrokenFixed = missingBroken(from.BrokenLinks, to.BrokenLinks)
+
+	diff.Changed = diff.Status != nil || diff.Title != nil || diff.HTMLVersion != nil ||
+		diff.HasLoginForm != nil || len(diff.Headings) > 0 || len(diff.LinksAdded) > 0 ||
+		len(diff.LinksRemoved) > 0 || len(diff.BrokenNew) > 0 || len(diff.BrokenFixed) > 0
+	return diff
+}
+
+// linksStored reports whether links are the stored links of result: results
+// saved before links were stored have counts but no links.
+func linksStored(result models.Result, links []models.PageLink) bool {
+	return links != nil && (len(links) > 0 || result.InternalLinks+result.ExternalLinks == 0)
+}
+
+// missingLinks returns the URLs linked in links but not in other, in
+// document order.
+func missingLinks(links, other []models.PageLink) []string {
+	known := make(map[string]bool, len(other))
+	for _, link := range other {
+		known[link.URL] = true
+	}
+	var missing []string
+	for _, link := range links {
+		if !known[link.URL] {
+			known[link.URL] = true
+			missing = append(missing, link.URL)
+		}
+	}
+	return missing
+}
+
+// missingBroken returns the broken links of broken that are not in other.
+func missingBroken(broken, other []models.BrokenLink) []models.BrokenLink {
+	known := make(map[string]bool, len(other))
+	for _, link := range other {
+		known[link.URL] = true
+	}
+	var missing []models.BrokenLink
+	for _, link := range broken {
+		if !known[link.URL] {
+			missing = append(missing, link)
+		}
+	}
+	return missing
+}
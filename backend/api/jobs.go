@@ -0,0 +1,76 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"scrawling_dashboard/backend/models"
+)
+
+// JobHandler returns a job with its state history.
+func JobHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := loadJob(w, r)
+	if !ok {
+		return
+	}
+	events, err := db.GetJobEvents(job.ID)
+	if err != nil {
+		log.Printf("Failed to load events of job %d: %v\n", job.ID, err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job":    job,
+		"events": events,
+	})
+}
+
+// JobResultHandler returns the results stored by a job: one row for a page
+// crawl, the start page and every crawled page for a site crawl.
+func JobResultHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := loadJob(w, r)
+	if !ok {
+		return
+	}
+	results, err := db.JobResults(job.ID)
+	if err != nil {
+		log.Printf("Failed to load results of job %d: %v\n", job.ID, err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	if len(results) == 0 {
+		http.Error(w, "Job has no result yet", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// loadJob reads the job named by the {id} path value, writing an error
+// response if it cannot.
+func loadJob(w http.ResponseWriter, r *http.Request) (models.Job, bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return models.Job{}, false
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return models.Job{}, false
+	}
+	job, err := db.GetJob(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return models.Job{}, false
+	} else if err != nil {
+		log.Printf("Failed to load job %d: %v\n", id, err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return models.Job{}, false
+	}
+	return job, true
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"scrawling_dashboard/backend/database"
+)
+
+// maxLinkSources caps the number of links returned by LinksHandler.
+const maxLinkSources = 1000
+
+// ResultLinksHandler returns every link of a crawled page in document order.
+func ResultLinksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid result ID", http.StatusBadRequest)
+		return
+	}
+	if _, err := db.GetResult(id); errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Result not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Failed to load result %d: %v\n", id, err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	links, err := db.ResultLinks(id)
+	if err != nil {
+		log.Printf("Failed to load links of result %d: %v\n", id, err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(links)
+}
+
+// LinksHandler returns the pages that linked to ?url=, or to any URL that
+// answered ?status= or was ?broken=true when it was checked, across every
+// crawl, newest first.
+func LinksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	filter := database.LinkFilter{URL: query.Get("url"), Limit: maxLinkSources}
+	if status := query.Get("status"); status != "" {
+		code, err := strconv.Atoi(status)
+		if err != nil || code <= 0 {
+			http.Error(w, "Invalid status", http.StatusBadRequest)
+			return
+		}
+		filter.StatusCode = code
+	}
+	if broken := query.Get("broken"); broken != "" {
+		b, err := strconv.ParseBool(broken)
+		if err != nil {
+			http.Error(w, "Invalid broken", http.StatusBadRequest)
+			return
+		}
+		filter.Broken = b
+	}
+	if filter.URL == "" && filter.StatusCode == 0 && !filter.Broken {
+		http.Error(w, "url, status or broken is required", http.StatusBadRequest)
+		return
+	}
+
+	sources, err := db.LinkSources(filter)
+	if err != nil {
+		log.Printf("Failed to load link sources: %v\n", err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sources)
+}
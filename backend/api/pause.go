@@ -0,0 +1,180 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"scrawling_dashboard/backend/models"
+)
+
+// setPoolPaused pauses or resumes every worker. Pausing interrupts the
+// running jobs, which their workers queue again; queued jobs stay queued
+// until the pool is resumed. It returns the IDs of the interrupted jobs.
+func setPoolPaused(paused bool) ([]int64, error) {
+	if err := db.SetPoolPaused(paused); err != nil {
+		return nil, err
+	}
+	interrupted := []int64{}
+	if paused {
+		var err error
+		if interrupted, err = db.InterruptJobs(models.JobQueued, 0); err != nil {
+			return nil, err
+		}
+	}
+	notifyWorkers()
+	return interrupted, nil
+}
+
+// setBatchPaused pauses or resumes a batch. Pausing holds its queued jobs,
+// jobs waiting for a retry included, and interrupts its running ones.
+// Resuming queues the held jobs again. It returns the IDs of the jobs held
+// or released.
+func setBatchPaused(id int64, paused bool) ([]int64, error) {
+	if err := db.SetBatchPaused(id, paused); err != nil {
+		return nil, err
+	}
+	defer notifyWorkers()
+	if !paused {
+		return db.MoveBatchJobs(id, models.JobPaused, models.JobQueued, "batch resumed")
+	}
+	held, err := db.MoveBatchJobs(id, models.JobQueued, models.JobPaused, "batch paused")
+	if err != nil {
+		return nil, err
+	}
+	interrupted, err := db.InterruptJobs(models.JobPaused, id)
+	if err != nil {
+		return nil, err
+	}
+	return append(held, interrupted...), nil
+}
+
+// PoolHandler reports whether the workers and which batches are paused,
+// along with the number of queued and held jobs.
+func PoolHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	state, err := pausedState()
+	if err != nil {
+		log.Printf("Failed to load pool state: %v\n", err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// PausePoolHandler stops the workers from starting jobs. Running jobs are
+// interrupted and queued again.
+func PausePoolHandler(w http.ResponseWriter, r *http.Request) {
+	pausePoolRequest(w, r, true)
+}
+
+// ResumePoolHandler lets the workers start jobs again.
+func ResumePoolHandler(w http.ResponseWriter, r *http.Request) {
+	pausePoolRequest(w, r, false)
+}
+
+func pausePoolRequest(w http.ResponseWriter, r *http.Request, paused bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	interrupted, err := setPoolPaused(paused)
+	if err != nil {
+		log.Printf("Failed to update pool state: %v\n", err)
+		http.Error(w, "Failed to update pool", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Crawl worker pool paused: %v\n", paused)
+
+	state, err := pausedState()
+	if err != nil {
+		log.Printf("Failed to load pool state: %v\n", err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	if paused {
+		state["interrupted"] = interrupted
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// pausedState describes what is paused.
+func pausedState() (map[string]interface{}, error) {
+	paused, err := db.PoolPaused()
+	if err != nil {
+		return nil, err
+	}
+	queued, err := db.CountJobs(models.JobQueued)
+	if err != nil {
+		return nil, err
+	}
+	held, err := db.CountJobs(models.JobPaused)
+	if err != nil {
+		return nil, err
+	}
+	batches, err := db.PausedBatches()
+	if err != nil {
+		return nil, err
+	}
+	if batches == nil {
+		batches = []int64{}
+	}
+	return map[string]interface{}{
+		"paused":         paused,
+		"queued":         queued,
+		"held":           held,
+		"paused_batches": batches,
+	}, nil
+}
+
+// PauseBatchHandler pauses the batch named by the {id} path value.
+func PauseBatchHandler(w http.ResponseWriter, r *http.Request) {
+	pauseBatchRequest(w, r, true)
+}
+
+// ResumeBatchHandler resumes the batch named by the {id} path value.
+func ResumeBatchHandler(w http.ResponseWriter, r *http.Request) {
+	pauseBatchRequest(w, r, false)
+}
+
+func pauseBatchRequest(w http.ResponseWriter, r *http.Request, paused bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid batch ID", http.StatusBadRequest)
+		return
+	}
+	ids, err := setBatchPaused(id, paused)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Batch not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Failed to update batch %d: %v\n", id, err)
+		http.Error(w, "Failed to update batch", http.StatusInternalServerError)
+		return
+	}
+
+	verb := "Paused"
+	if !paused {
+		verb = "Resumed"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  fmt.Sprintf("%s %d job(s)", verb, len(ids)),
+		"batch_id": id,
+		"paused":   paused,
+		"job_ids":  ids,
+	})
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"sort"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+// defaultJobDuration is the ETA estimate used before any job has finished.
+const defaultJobDuration = 30 * time.Second
+
+// jobQueue orders queued jobs the way workers claim them: by priority, and
+// within a priority, groups (projects or users) take turns, so one group's
+// large submission does not hold back everyone else; each group's jobs run
+// in submission order.
+type jobQueue struct {
+	lanes [3]queueLane
+	size  int
+}
+
+// queueLane is the round-robin of groups of one priority.
+type queueLane struct {
+	groups map[string][]models.Job
+	turns  []string // groups in the order they get their next turn
+}
+
+// Push adds job at the end of its group.
+func (q *jobQueue) Push(job models.Job) {
+	lane := &q.lanes[models.PriorityRank(job.Options.Priority)]
+	if lane.groups == nil {
+		lane.groups = make(map[string][]models.Job)
+	}
+	group := jobGroup(job)
+	if len(lane.groups[group]) == 0 {
+		lane.turns = append(lane.turns, group)
+	}
+	lane.groups[group] = append(lane.groups[group], job)
+	q.size++
+}
+
+// Order returns the queued job IDs in the order they will be claimed.
+func (q *jobQueue) Order() []int64 {
+	order := make([]int64, 0, q.size)
+	for rank := len(q.lanes) - 1; rank >= 0; rank-- {
+		lane := q.lanes[rank]
+		next := make(map[string]int, len(lane.turns))
+		turns := append([]string(nil), lane.turns...)
+		for len(turns) > 0 {
+			group := turns[0]
+			turns = turns[1:]
+			order = append(order, lane.groups[group][next[group]].ID)
+			next[group]++
+			if next[group] < len(lane.groups[group]) {
+				turns = append(turns, group)
+			}
+		}
+	}
+	return order
+}
+
+// queueOrder returns the IDs of the queued jobs in the order workers will
+// claim them. turns holds when each group last had a job claimed; groups
+// that waited longest take the first turns.
+func queueOrder(jobs []models.Job, turns map[string]time.Time) []int64 {
+	sorted := append([]models.Job(nil), jobs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ti, tj := turns[jobGroup(sorted[i])], turns[jobGroup(sorted[j])]
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	var q jobQueue
+	for _, job := range sorted {
+		q.Push(job)
+	}
+	return q.Order()
+}
+
+// jobGroup is the fairness group of a job.
+func jobGroup(job models.Job) string {
+	if job.Options.Project != "" {
+		return job.Options.Project
+	}
+	return "default"
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+func TestQueueOrder(t *testing.T) {
+	jobs := []models.Job{
+		{ID: 1, Options: models.CrawlOptions{Project: "big"}},
+		{ID: 2, Options: models.CrawlOptions{Project: "big"}},
+		{ID: 3, Options: models.CrawlOptions{Project: "big"}},
+		{ID: 4, Options: models.CrawlOptions{Project: "small"}},
+		{ID: 5, Options: models.CrawlOptions{Priority: models.PriorityLow}},
+		{ID: 6, Options: models.CrawlOptions{Priority: models.PriorityHigh, Project: "big"}},
+		{ID: 7},
+	}
+
+	// High first, then the normal groups take turns, then low.
+	if got, want := queueOrder(jobs, nil), []int64{6, 1, 4, 7, 2, 3, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("queueOrder = %v, want %v", got, want)
+	}
+
+	// "big" had a job claimed last, so the other groups go first.
+	now := time.Now()
+	turns := map[string]time.Time{"big": now, "small": now.Add(-time.Minute)}
+	if got, want := queueOrder(jobs, turns), []int64{6, 7, 4, 1, 2, 3, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("queueOrder after turns = %v, want %v", got, want)
+	}
+}
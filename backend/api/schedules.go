@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"scrawling_dashboard/backend/crawler"
+	"scrawling_dashboard/backend/models"
+	"scrawling_dashboard/backend/scheduler"
+)
+
+// StartScheduler starts enqueuing the crawls of stored schedules. Runs
+// missed while the server was down are handled right away.
+func StartScheduler() {
+	go scheduler.New(db, enqueueCrawl).Run(context.Background())
+}
+
+// SchedulesHandler lists schedules (GET) and creates them (POST).
+func SchedulesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		schedules, err := db.ListSchedules()
+		if err != nil {
+			log.Printf("Failed to list schedules: %v\n", err)
+			http.Error(w, "Query failed", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(schedules)
+	case http.MethodPost:
+		schedule := models.Schedule{Enabled: true}
+		if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+		if err := prepareSchedule(&schedule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id, err := db.CreateSchedule(schedule)
+		if err != nil {
+			log.Printf("Failed to create schedule: %v\n", err)
+			http.Error(w, "Failed to create schedule", http.StatusInternalServerError)
+			return
+		}
+		writeSchedule(w, id, http.StatusCreated)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ScheduleHandler reads (GET), replaces (PUT) or deletes (DELETE) the
+// schedule named by the {id} path value.
+func ScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid schedule ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeSchedule(w, id, http.StatusOK)
+	case http.MethodPut:
+		existing, err := db.GetSchedule(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Schedule not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("Failed to load schedule %d: %v\n", id, err)
+			http.Error(w, "Query failed", http.StatusInternalServerError)
+			return
+		}
+		schedule := existing
+		if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+		schedule.ID = id
+		if err := prepareSchedule(&schedule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := db.UpdateSchedule(schedule); err != nil {
+			log.Printf("Failed to update schedule %d: %v\n", id, err)
+			http.Error(w, "Failed to update schedule", http.StatusInternalServerError)
+			return
+		}
+		writeSchedule(w, id, http.StatusOK)
+	case http.MethodDelete:
+		err := db.DeleteSchedule(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Schedule not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("Failed to delete schedule %d: %v\n", id, err)
+			http.Error(w, "Failed to delete schedule", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// prepareSchedule validates a submitted schedule and plans its next run
+// from now, so runs missed before an edit are not caught up on.
+func prepareSchedule(schedule *models.Schedule) error {
+	// Scheduled runs are stored under the same URL as submitted crawls.
+	url, err := crawler.NormalizeURL(schedule.URL)
+	if err != nil {
+		return errors.New("Invalid url: " + err.Error())
+	}
+	schedule.URL = url
+	switch schedule.MissedPolicy {
+	case "":
+		schedule.MissedPolicy = models.MissedSkip
+	case models.MissedSkip, models.MissedCatchUp:
+	default:
+		return errors.New("Invalid missed_policy")
+	}
+	if err := validateOptions(&schedule.Options); err != nil {
+		return err
+	}
+	next, err := scheduler.NextRun(schedule.Cron, time.Now())
+	if err != nil {
+		return err
+	}
+	schedule.NextRunAt = next
+	return nil
+}
+
+// writeSchedule responds with the stored schedule id.
+func writeSchedule(w http.ResponseWriter, id int64, status int) {
+	schedule, err := db.GetSchedule(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Failed to load schedule %d: %v\n", id, err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(schedule)
+}
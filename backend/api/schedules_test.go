@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"scrawling_dashboard/backend/models"
+)
+
+func TestSchedulesHandlerNormalizesURL(t *testing.T) {
+	useTestStore(t)
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		SchedulesHandler(rec, httptest.NewRequest(http.MethodPost, "/api/schedules", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := post(`{"name": "home", "cron": "0 * * * *", "url": " Example.com "}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var schedule models.Schedule
+	if err := json.Unmarshal(rec.Body.Bytes(), &schedule); err != nil {
+		t.Fatal(err)
+	}
+	// Scheduled runs must add to the history of the URL crawls submit.
+	if schedule.URL != "https://example.com/" {
+		t.Errorf("schedule URL = %q, want https://example.com/", schedule.URL)
+	}
+
+	if rec := post(`{"name": "ftp", "cron": "0 * * * *", "url": "ftp://example.com/"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("unsupported scheme: status %d, want 400", rec.Code)
+	}
+}
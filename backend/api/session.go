@@ -28,3 +28,10 @@ func IsAuthenticated(r *http.Request) bool {
 	auth, ok := session.Values["authenticated"].(bool)
 	return ok && auth
 }
+
+// sessionUser returns the name of the logged in user, or "".
+func sessionUser(r *http.Request) string {
+	session, _ := GetSession(r)
+	user, _ := session.Values["username"].(string)
+	return user
+}
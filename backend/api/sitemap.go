@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"scrawling_dashboard/backend/crawler"
+	"scrawling_dashboard/backend/models"
+)
+
+const sitemapTimeout = 2 * time.Minute
+
+// robotsCache looks sitemaps up in robots.txt, once per host.
+var robotsCache = crawler.NewRobotsCache()
+
+// handleSitemapCrawl queues every page listed by the submitted or
+// discovered sitemaps as one batch. It fails if none of the sitemaps can be
+// read.
+func handleSitemapCrawl(w http.ResponseWriter, r *http.Request, payload models.RequestPayload) {
+	var since time.Time
+	if payload.LastModSince != "" {
+		t, ok := crawler.ParseW3CDate(payload.LastModSince)
+		if !ok {
+			http.Error(w, "Invalid lastmod_since", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), sitemapTimeout)
+	defer cancel()
+
+	sitemaps := []string{payload.Sitemap}
+	if payload.Sitemap == "" {
+		found, err := robotsCache.Sitemaps(ctx, payload.URL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sitemaps = found
+	}
+
+	reader := crawler.NewSitemapReader()
+	seen := map[string]bool{}
+	var (
+		urls    []string
+		lastErr error
+		failed  int
+		skipped int // URLs beyond maxBatchURLs
+	)
+	for _, sitemap := range sitemaps {
+		locs, err := reader.Collect(ctx, sitemap, since)
+		if err != nil {
+			log.Printf("Sitemap %s failed: %v\n", sitemap, err)
+			lastErr = err
+			failed++
+			continue
+		}
+		for _, loc := range locs {
+			url, err := crawler.NormalizeURL(loc)
+			if err != nil || seen[url] {
+				continue
+			}
+			seen[url] = true
+			if len(urls) >= maxBatchURLs {
+				skipped++
+				continue
+			}
+			urls = append(urls, url)
+		}
+	}
+	if failed > 0 && failed == len(sitemaps) {
+		message := lastErr.Error()
+		if failed > 1 {
+			message = fmt.Sprintf("All %d sitemaps failed, the last with: %v", failed, lastErr)
+		}
+		http.Error(w, message, http.StatusBadGateway)
+		return
+	}
+
+	var batchID int64
+	jobIDs := []int64{}
+	if len(urls) > 0 {
+		var err error
+		batchID, jobIDs, err = queueBatch(models.Batch{
+			Source:   "sitemap",
+			Total:    len(urls) + skipped,
+			Accepted: len(urls),
+			Rejected: skipped,
+		}, urls, payload.CrawlOptions)
+		if err != nil {
+			log.Printf("Failed to queue sitemap batch: %v\n", err)
+			http.Error(w, "Failed to queue crawl", http.StatusInternalServerError)
+			return
+		}
+	}
+	log.Printf("Enqueued %d URLs from %d sitemap(s) as batch %d\n", len(jobIDs), len(sitemaps), batchID)
+
+	message := "Added to crawl queue"
+	if skipped > 0 {
+		message = fmt.Sprintf("Added the first %d URLs to crawl queue; %d more were skipped (max %d per batch)",
+			len(jobIDs), skipped, maxBatchURLs)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  message,
+		"sitemaps": sitemaps,
+		"batch_id": batchID,
+		"enqueued": len(jobIDs),
+		"skipped":  skipped,
+		"job_ids":  jobIDs,
+	})
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+	"scrawling_dashboard/backend/worker"
+)
+
+// workerTimeout is how long a worker that stopped reporting is still listed.
+const workerTimeout = worker.DefaultLease
+
+// workers is the pool running in the API process, or nil when crawls run
+// only in separate worker processes.
+var workers *worker.Pool
+
+// StartWorkers runs a worker pool in the API process, unless CRAWL_WORKERS
+// is 0 because crawls run in separate worker processes.
+func StartWorkers() {
+	if os.Getenv("CRAWL_WORKERS") == "0" {
+		log.Println("No crawl workers in this process; run cmd/worker to crawl")
+		return
+	}
+	workers = worker.New(db)
+	go workers.Run(context.Background())
+}
+
+// notifyWorkers makes the local pool pick up new jobs and cancel and pause
+// requests at once. Other workers see them at their next poll.
+func notifyWorkers() {
+	if workers != nil {
+		workers.Wake()
+	}
+}
+
+// workerPool describes the crawl workers for the progress and pool APIs.
+type workerPool struct {
+	Paused  bool            `json:"paused"`
+	Slots   int             `json:"slots"`
+	Running int             `json:"running"`
+	Workers []models.Worker `json:"instances"`
+}
+
+func poolState() (workerPool, error) {
+	paused, err := db.PoolPaused()
+	if err != nil {
+		return workerPool{}, err
+	}
+	instances, err := db.ListWorkers(workerTimeout)
+	if err != nil {
+		return workerPool{}, err
+	}
+	pool := workerPool{Paused: paused, Workers: instances}
+	for _, instance := range instances {
+		pool.Slots += instance.Slots
+		pool.Running += instance.Running
+	}
+	return pool, nil
+}
+
+// queueStatus returns the state of the unfinished jobs and of the jobs that
+// finished within finishedJobTTL. Queued jobs carry their queue position
+// and ETA.
+func queueStatus() (map[int64]jobStatus, error) {
+	jobs, err := db.ActiveJobs(time.Now().Add(-finishedJobTTL))
+	if err != nil {
+		return nil, err
+	}
+	turns, err := db.ProjectTurns()
+	if err != nil {
+		return nil, err
+	}
+	avg, err := db.AverageJobDuration()
+	if err != nil {
+		return nil, err
+	}
+	if avg <= 0 {
+		avg = defaultJobDuration
+	}
+	pool, err := poolState()
+	if err != nil {
+		return nil, err
+	}
+	slots := max(pool.Slots, 1)
+
+	statuses := make(map[int64]jobStatus, len(jobs))
+	var queued []models.Job
+	for _, job := range jobs {
+		statuses[job.ID] = newJobStatus(job)
+		if job.State == models.JobQueued && (job.RunAfter == nil || !job.RunAfter.After(time.Now())) {
+			queued = append(queued, job)
+		}
+	}
+	for i, id := range queueOrder(queued, turns) {
+		status := statuses[id]
+		status.Position = i + 1
+		// Jobs start in batches of the workers' slots, after the running ones.
+		status.ETASeconds = float64(i/slots+1) * avg.Seconds()
+		statuses[id] = status
+	}
+	return statuses, nil
+}
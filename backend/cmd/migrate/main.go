@@ -0,0 +1,81 @@
+// Command migrate shows, applies and reverts the schema migrations of the
+// database named by the same settings as the server.
+//
+//	go run ./cmd/migrate status     list the migrations and which are applied
+//	go run ./cmd/migrate up [n]     apply the pending ones, up to version n
+//	go run ./cmd/migrate down [n]   revert the latest n applied ones (default 1)
+//
+// The first migration, which holds the tables of databases created before
+// migrations, is never reverted.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/joho/godotenv"
+
+	"scrawling_dashboard/backend/database"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, continuing...")
+	}
+
+	command := "status"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+	n := 0
+	if len(os.Args) > 2 {
+		var err error
+		if n, err = strconv.Atoi(os.Args[2]); err != nil || n < 1 {
+			log.Fatalf("Invalid count %q", os.Args[2])
+		}
+	}
+
+	store := database.Open()
+	defer store.Close()
+
+	switch command {
+	case "status":
+		status, err := store.MigrationStatus()
+		if err != nil {
+			log.Fatalf("Failed to read migrations: %v", err)
+		}
+		for _, m := range status {
+			state := "pending"
+			switch {
+			case m.Dirty:
+				state = "dirty"
+			case m.AppliedAt != nil:
+				state = "applied " + m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			name := m.Name
+			if name == "" {
+				name = "(unknown to this build)"
+			}
+			fmt.Printf("%4d  %-30s %s\n", m.Version, name, state)
+		}
+	case "up":
+		applied, err := store.MigrateUp(n)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		if _, err := store.MigrateDown(n); err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+	default:
+		log.Fatalf("Unknown command %q; use status, up or down", command)
+	}
+}
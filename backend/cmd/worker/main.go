@@ -0,0 +1,30 @@
+// Command worker runs crawl jobs from the shared database queue, next to or
+// instead of the workers of the API server. Run as many as needed, on any
+// number of machines.
+package main
+
+import (
+	"context"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/joho/godotenv"
+
+	"scrawling_dashboard/backend/database"
+	"scrawling_dashboard/backend/worker"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, continuing...")
+	}
+
+	store := database.Connect()
+
+	// On SIGINT or SIGTERM the running jobs are queued again for other
+	// workers before the process exits.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	worker.New(store).Run(ctx)
+}
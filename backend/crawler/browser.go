@@ -0,0 +1,79 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Browser is a headless Chrome process shared by concurrent crawls. It is
+// started on first use, and every fetch gets its own tab.
+type Browser struct {
+	mu            sync.Mutex
+	allocCancel   context.CancelFunc
+	browserCtx    context.Context
+	browserCancel context.CancelFunc
+}
+
+func NewBrowser() *Browser {
+	return &Browser{}
+}
+
+// NewTab opens a tab that is closed by the returned cancel function or when
+// ctx is done.
+func (b *Browser) NewTab(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	browserCtx, err := b.start()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tabCtx, cancel := chromedp.NewContext(browserCtx)
+	// The tab derives from the browser, so tie it to the caller's context.
+	stop := context.AfterFunc(ctx, cancel)
+	return tabCtx, func() {
+		stop()
+		cancel()
+	}, nil
+}
+
+// start launches Chrome unless it is already running.
+func (b *Browser) start() (context.Context, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.browserCtx != nil && b.browserCtx.Err() == nil {
+		return b.browserCtx, nil
+	}
+	b.closeLocked()
+
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(),
+		chromedp.DefaultExecAllocatorOptions[:]...)
+	browserCtx, browserCancel := chromedp.NewContext(allocCtx)
+	// Running no actions just starts the browser.
+	if err := chromedp.Run(browserCtx); err != nil {
+		browserCancel()
+		allocCancel()
+		return nil, fmt.Errorf("failed to start chrome: %w", err)
+	}
+
+	b.allocCancel, b.browserCtx, b.browserCancel = allocCancel, browserCtx, browserCancel
+	return browserCtx, nil
+}
+
+// Close shuts Chrome down. Tabs still open are canceled; the next NewTab
+// starts a new browser.
+func (b *Browser) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closeLocked()
+}
+
+func (b *Browser) closeLocked() {
+	if b.browserCancel != nil {
+		b.browserCancel()
+		b.allocCancel()
+	}
+	b.allocCancel, b.browserCtx, b.browserCancel = nil, nil, nil
+}
@@ -3,64 +3,142 @@ package crawler
 import (
 	"context"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/chromedp/chromedp"
 
 	"scrawling_dashboard/backend/models"
 )
 
-// CrawlURLWithContext crawls a URL and returns the crawl result.
+// Crawler analyzes pages loaded by its Fetcher and verifies their links
+// with its LinkChecker. If Robots is set, pages are only fetched when
+// robots.txt allows it for RobotsAgent. Page loads wait for Limiter. The
+// phases of each page are reported to Progress, if set.
+type Crawler struct {
+	Fetcher     Fetcher
+	Links       *LinkChecker
+	Robots      *RobotsCache
+	RobotsAgent string
+	Limiter     Limiters
+	Progress    func(Progress)
+}
+
+// Progress is the phase a crawl of URL is in. Done and Total count the
+// checked links while checking links.
+type Progress struct {
+	URL   string
+	Phase string
+	Done  int
+	Total int
+}
+
+func (c *Crawler) report(p Progress) {
+	if c.Progress != nil {
+		c.Progress(p)
+	}
+}
+
+// New returns a crawler that loads pages with f and checks links with the
+// default LinkChecker.
+func New(f Fetcher) *Crawler {
+	return &Crawler{Fetcher: f, Links: NewLinkChecker(0, 0)}
+}
+
+// CrawlURLWithContext crawls a URL with headless Chrome and returns the crawl result.
 func CrawlURLWithContext(ctx context.Context, targetURL string) (*models.CrawlResult, error) {
-	cdpCtx, cancel := chromedp.NewContext(ctx)
-	defer cancel()
-
-	var htmlContent, doctypeStr, pageTitle string
-	if err := chromedp.Run(cdpCtx,
-		chromedp.Navigate(targetURL),
-		chromedp.Evaluate(`document.documentElement.outerHTML`, &htmlContent),
-		chromedp.Evaluate(`(function() {
-			if (document.doctype) {
-				return "<!DOCTYPE "
-					+ document.doctype.name
-					+ (document.doctype.publicId ? ' PUBLIC "' + document.doctype.publicId + '"' : '')
-					+ (document.doctype.systemId ? ' "' + document.doctype.systemId + '"' : '')
-					+ ">";
-			}
-			return "";
-		})()`, &doctypeStr),
-		chromedp.Title(&pageTitle),
-	); err != nil {
-		return nil, fmt.Errorf("chromedp failed: %w", err)
+	return New(ChromeFetcher{}).CrawlURL(ctx, targetURL)
+}
+
+// CrawlURL crawls a URL and returns the crawl result.
+func (c *Crawler) CrawlURL(ctx context.Context, targetURL string) (*models.CrawlResult, error) {
+	result, _, err := c.analyzePage(ctx, targetURL)
+	return result, err
+}
+
+// analyzePage crawls a single page and also returns the parsed document so
+// callers can walk its links.
+func (c *Crawler) analyzePage(ctx context.Context, targetURL string) (*models.CrawlResult, *goquery.Document, error) {
+	timings := &models.PhaseTimings{}
+	start := time.Now()
+	mark := start
+	// lap returns the time since the previous lap in milliseconds.
+	lap := func() int64 {
+		now := time.Now()
+		d := now.Sub(mark)
+		mark = now
+		return d.Milliseconds()
+	}
+
+	if c.Robots != nil {
+		if err := c.Robots.Admit(ctx, targetURL, c.RobotsAgent); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if u, err := url.Parse(targetURL); err == nil {
+		if err := c.Limiter.Wait(ctx, u.Hostname()); err != nil {
+			return nil, nil, fmt.Errorf("crawl canceled: %w", err)
+		}
+	}
+	timings.Wait = lap()
+
+	c.report(Progress{URL: targetURL, Phase: models.PhaseNavigating})
+	page, err := c.Fetcher.Fetch(ctx, targetURL)
+	if err != nil {
+		return nil, nil, err
 	}
+	timings.Navigation = lap()
 
 	if err := ctx.Err(); err != nil {
-		return nil, fmt.Errorf("crawl canceled")
+		return nil, nil, fmt.Errorf("crawl canceled: %w", err)
 	}
 
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
+	c.report(Progress{URL: targetURL, Phase: models.PhaseParsing})
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page.HTML))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
-	doctype := parseDoctype(doctypeStr)
+	doctype := parseDoctype(page.Doctype)
+	pageTitle := page.Title
+	if pageTitle == "" {
+		pageTitle = strings.TrimSpace(doc.Find("title").First().Text())
+	}
+	timings.DOMExtraction = lap()
+
+	c.report(Progress{URL: targetURL, Phase: models.PhaseHeadings})
 	headings, err := parseHeadings(ctx, doc)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	timings.HeadingParse = lap()
 
-	internal, external, broken, err := parseLinks(ctx, doc, targetURL)
+	// Relative links resolve against the page the redirects ended on.
+	pageURL := page.URL
+	if pageURL == "" {
+		pageURL = targetURL
+	}
+	links, broken, linkRedirects, err := c.parseLinks(ctx, doc, targetURL, pageURL)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	internal, external := 0, 0
+	for _, link := range links {
+		if link.External {
+			external++
+		} else {
+			internal++
+		}
+	}
+	timings.LinkCheck = lap()
 
+	c.report(Progress{URL: targetURL, Phase: models.PhaseLoginDetection})
 	hasLogin := detectLoginForm(ctx, doc)
+	timings.LoginDetection = lap()
+	timings.Total = time.Since(start).Milliseconds()
 
 	return &models.CrawlResult{
 		URL:           targetURL,
@@ -70,8 +148,12 @@ func CrawlURLWithContext(ctx context.Context, targetURL string) (*models.CrawlRe
 		InternalLinks: internal,
 		ExternalLinks: external,
 		BrokenLinks:   broken,
+		Links:         links,
 		HasLoginForm:  hasLogin,
-	}, nil
+		Redirects:     page.Redirects,
+		LinkRedirects: linkRedirects,
+		Timings:       timings,
+	}, doc, nil
 }
 
 // parseDoctype extracts the doctype from the string.
@@ -89,7 +171,7 @@ func parseHeadings(ctx context.Context, doc *goquery.Document) (map[string]int,
 	headings := map[string]int{}
 	for i := 1; i <= 6; i++ {
 		if err := ctx.Err(); err != nil {
-			return nil, fmt.Errorf("crawl canceled")
+			return nil, fmt.Errorf("crawl canceled: %w", err)
 		}
 		tag := fmt.Sprintf("h%d", i)
 		headings[tag] = doc.Find(tag).Length()
@@ -97,77 +179,109 @@ func parseHeadings(ctx context.Context, doc *goquery.Document) (map[string]int,
 	return headings, nil
 }
 
-// parseLinks counts internal/external links and finds broken links.
-func parseLinks(ctx context.Context, doc *goquery.Document, targetURL string) (int, int, []models.BrokenLink, error) {
-	parsedBase, err := url.Parse(targetURL)
+// parseLinks lists the links of a page in document order and finds broken
+// and redirected links. Every unique URL is checked once. Links are resolved
+// against pageURL, where the crawl of targetURL ended up.
+func (c *Crawler) parseLinks(ctx context.Context, doc *goquery.Document, targetURL, pageURL string) ([]models.PageLink, []models.BrokenLink, []models.RedirectChain, error) {
+	parsedBase, err := url.Parse(pageURL)
 	if err != nil {
-		return 0, 0, nil, fmt.Errorf("invalid base URL")
+		return nil, nil, nil, fmt.Errorf("invalid base URL")
 	}
 	baseHost := parsedBase.Hostname()
-	client := &http.Client{Timeout: 10 * time.Second}
-
-	internal, external := 0, 0
-	broken := []models.BrokenLink{}
 
-	forEachLink := func(fn func(link *url.URL)) {
-		doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
-			if err := ctx.Err(); err != nil {
-				return
-			}
-			href, _ := s.Attr("href")
-			link, err := parsedBase.Parse(href)
-			if err != nil {
-				return
-			}
-			fn(link)
-		})
-	}
+	links := []models.PageLink{}
+	seen := map[string]bool{}
+	unique := []*url.URL{}
 
-	classifyLink := func(link *url.URL) string {
-		if link.Hostname() == "" || link.Hostname() == baseHost {
-			return "internal"
+	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		link, err := parsedBase.Parse(href)
+		if err != nil {
+			return
 		}
-		return "external"
-	}
-
-	isBrokenLink := func(link *url.URL) (bool, int) {
-		if link.Scheme != "http" && link.Scheme != "https" {
-			return false, 0
+		// The fragment is never sent, so /a#x and /a are the same link.
+		link.Fragment, link.RawFragment = "", ""
+		pageLink := models.PageLink{
+			URL:      link.String(),
+			Anchor:   anchorText(s),
+			External: link.Hostname() != "" && link.Hostname() != baseHost,
 		}
-		req, _ := http.NewRequestWithContext(ctx, http.MethodHead, link.String(), nil)
-		resp, err := client.Do(req)
-		if err != nil || (resp != nil && resp.StatusCode >= 400) {
-			status := 0
-			if resp != nil {
-				status = resp.StatusCode
-				io.Copy(io.Discard, resp.Body)
-				resp.Body.Close()
+		rel, _ := s.Attr("rel")
+		for _, value := range strings.Fields(strings.ToLower(rel)) {
+			switch value {
+			case "nofollow":
+				pageLink.Nofollow = true
+			case "sponsored":
+				pageLink.Sponsored = true
+			case "ugc":
+				pageLink.UGC = true
 			}
-			return true, status
 		}
-		if resp != nil {
-			io.Copy(io.Discard, resp.Body)
-			resp.Body.Close()
+		links = append(links, pageLink)
+		if !seen[pageLink.URL] {
+			seen[pageLink.URL] = true
+			unique = append(unique, link)
 		}
-		return false, 0
+	})
+
+	c.report(Progress{URL: targetURL, Phase: models.PhaseCheckingLinks, Total: len(unique)})
+	statuses := c.Links.CheckAllProgress(ctx, unique, func(done int) {
+		c.report(Progress{URL: targetURL, Phase: models.PhaseCheckingLinks, Done: done, Total: len(unique)})
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, fmt.Errorf("crawl canceled: %w", err)
 	}
 
-	forEachLink(func(link *url.URL) {
-		switch classifyLink(link) {
-		case "internal":
-			internal++
-		case "external":
-			external++
+	for i := range links {
+		status := statuses[links[i].URL]
+		links[i].Broken = status.Broken
+		links[i].StatusCode = status.StatusCode
+		links[i].Reason = status.Reason
+		if !status.CheckedAt.IsZero() {
+			checkedAt := status.CheckedAt
+			links[i].CheckedAt = &checkedAt
 		}
-		if brokenLink, status := isBrokenLink(link); brokenLink {
+	}
+
+	broken := []models.BrokenLink{}
+	redirected := []models.RedirectChain{}
+	for _, link := range unique {
+		status := statuses[link.String()]
+		if status.Broken {
 			broken = append(broken, models.BrokenLink{
 				URL:        link.String(),
-				StatusCode: status,
+				StatusCode: status.StatusCode,
+				Reason:     status.Reason,
 			})
 		}
-	})
+		if status.Redirects != nil {
+			redirected = append(redirected, *status.Redirects)
+		}
+	}
 
-	return internal, external, broken, nil
+	return links, broken, redirected, nil
+}
+
+// maxAnchorLength caps the stored anchor text of a link, in runes.
+const maxAnchorLength = 500
+
+// anchorText returns the text of a link with its whitespace collapsed, or
+// the alt text of its images for image links.
+func anchorText(s *goquery.Selection) string {
+	text := strings.Join(strings.Fields(s.Text()), " ")
+	if text == "" {
+		var alts []string
+		s.Find("img[alt]").Each(func(i int, img *goquery.Selection) {
+			if alt := strings.TrimSpace(img.AttrOr("alt", "")); alt != "" {
+				alts = append(alts, alt)
+			}
+		})
+		text = strings.Join(alts, " ")
+	}
+	if runes := []rune(text); len(runes) > maxAnchorLength {
+		text = string(runes[:maxAnchorLength])
+	}
+	return text
 }
 
 // detectLoginForm checks for login forms or keywords.
@@ -0,0 +1,106 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+func TestCrawlURLPhasesAndTimings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			time.Sleep(20 * time.Millisecond)
+			fmt.Fprint(w, `<html><h1>Title</h1><a href="/a">a</a></html>`)
+		}
+	}))
+	defer srv.Close()
+
+	var phases []string
+	c := New(&StaticFetcher{Client: srv.Client()})
+	c.Progress = func(p Progress) {
+		if len(phases) == 0 || phases[len(phases)-1] != p.Phase {
+			phases = append(phases, p.Phase)
+		}
+	}
+	result, err := c.CrawlURL(context.Background(), srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{models.PhaseNavigating, models.PhaseParsing, models.PhaseHeadings,
+		models.PhaseCheckingLinks, models.PhaseLoginDetection}
+	if !reflect.DeepEqual(phases, want) {
+		t.Errorf("phases = %v, want %v", phases, want)
+	}
+
+	timings := result.Timings
+	if timings == nil {
+		t.Fatal("no timings recorded")
+	}
+	if timings.Navigation < 20 {
+		t.Errorf("navigation took %dms, want at least the 20ms the page took", timings.Navigation)
+	}
+	sum := timings.Wait + timings.Navigation + timings.DOMExtraction + timings.HeadingParse +
+		timings.LinkCheck + timings.LoginDetection
+	if timings.Total < sum || timings.DBWrite != 0 {
+		t.Errorf("timings = %+v, want a total covering the crawl phases and no DB write yet", timings)
+	}
+}
+
+func TestCrawlURLLinks(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html>
+<a href="/a">  First
+  link </a>
+<a href="/a#top" rel="NoFollow UGC"><img alt="logo"></a>
+<a href="/gone">gone</a>
+<a href="http://localhost:1/" rel="sponsored">partner</a>
+</html>`)
+		case "/a":
+			requests++
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(&StaticFetcher{Client: srv.Client()})
+	result, err := c.CrawlURL(context.Background(), srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []models.PageLink{
+		{URL: srv.URL + "/a", Anchor: "First link", StatusCode: http.StatusOK},
+		{URL: srv.URL + "/a", Anchor: "logo", Nofollow: true, UGC: true, StatusCode: http.StatusOK},
+		{URL: srv.URL + "/gone", Anchor: "gone", Broken: true, StatusCode: http.StatusNotFound, Reason: models.ReasonHTTPStatus},
+		{URL: "http://localhost:1/", Anchor: "partner", Sponsored: true, External: true, Broken: true, Reason: models.ReasonConnectionRefused},
+	}
+	if len(result.Links) != len(want) {
+		t.Fatalf("links = %+v, want %d of them", result.Links, len(want))
+	}
+	for i, link := range result.Links {
+		if link.CheckedAt == nil {
+			t.Errorf("link %d has no check time", i)
+		}
+		link.CheckedAt = nil
+		if link != want[i] {
+			t.Errorf("link %d = %+v, want %+v", i, link, want[i])
+		}
+	}
+	if requests != 1 {
+		t.Errorf("/a was requested %d times, want once for all its fragments", requests)
+	}
+	if len(result.BrokenLinks) != 2 {
+		t.Errorf("broken links = %+v, want the two unique broken ones", result.BrokenLinks)
+	}
+}
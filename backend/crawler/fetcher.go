@@ -0,0 +1,216 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+
+	"scrawling_dashboard/backend/models"
+)
+
+const (
+	FetcherChrome = "chrome"
+	FetcherStatic = "static"
+
+	DefaultUserAgent = "Mozilla/5.0 (compatible; ScrawlingDashboard/1.0)"
+
+	maxStaticBodySize = 10 << 20
+)
+
+// UserAgent returns the User-Agent sent with crawler requests, taken from
+// CRAWLER_USER_AGENT if set.
+func UserAgent() string {
+	if ua := os.Getenv("CRAWLER_USER_AGENT"); ua != "" {
+		return ua
+	}
+	return DefaultUserAgent
+}
+
+// Page is the content of a fetched page.
+type Page struct {
+	URL       string
+	HTML      string
+	Doctype   string
+	Title     string
+	Redirects *models.RedirectChain
+}
+
+// Fetcher loads a page and returns its HTML.
+type Fetcher interface {
+	Fetch(ctx context.Context, targetURL string) (*Page, error)
+}
+
+// NewFetcher returns the fetcher for a crawl mode. An empty mode uses the
+// CRAWLER_FETCHER environment variable and falls back to Chrome. Chrome
+// fetchers open tabs in browser, or start their own Chrome if it is nil.
+func NewFetcher(mode string, browser *Browser) (Fetcher, error) {
+	if mode == "" {
+		mode = os.Getenv("CRAWLER_FETCHER")
+	}
+	switch mode {
+	case "", FetcherChrome:
+		return ChromeFetcher{Browser: browser}, nil
+	case FetcherStatic:
+		return &StaticFetcher{Client: &http.Client{Timeout: 30 * time.Second}}, nil
+	default:
+		return nil, fmt.Errorf("unknown fetcher %q", mode)
+	}
+}
+
+// ChromeFetcher renders pages in headless Chrome so that scripts run before
+// the HTML is read. With a Browser each fetch uses a tab of that shared
+// browser; otherwise it starts a browser of its own.
+type ChromeFetcher struct {
+	Browser *Browser
+}
+
+func (f ChromeFetcher) Fetch(ctx context.Context, targetURL string) (*Page, error) {
+	var (
+		cdpCtx context.Context
+		cancel context.CancelFunc
+	)
+	if f.Browser != nil {
+		var err error
+		if cdpCtx, cancel, err = f.Browser.NewTab(ctx); err != nil {
+			return nil, err
+		}
+	} else {
+		cdpCtx, cancel = chromedp.NewContext(ctx)
+	}
+	defer cancel()
+
+	// Record the responses of the main document, redirects included.
+	redirects := newRedirectRecorder(targetURL)
+	var (
+		mu        sync.Mutex
+		mainFrame cdp.FrameID
+		status    int
+	)
+	chromedp.ListenTarget(cdpCtx, func(ev interface{}) {
+		switch e := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			if e.Type != network.ResourceTypeDocument {
+				return
+			}
+			mu.Lock()
+			if mainFrame == "" {
+				mainFrame = e.FrameID
+			}
+			isMain := e.FrameID == mainFrame
+			mu.Unlock()
+			if isMain && e.RedirectResponse != nil {
+				redirects.add(e.RedirectResponse.URL, int(e.RedirectResponse.Status))
+			}
+		case *network.EventResponseReceived:
+			if e.Type != network.ResourceTypeDocument {
+				return
+			}
+			mu.Lock()
+			isMain := e.FrameID == mainFrame
+			if isMain {
+				status = int(e.Response.Status)
+			}
+			mu.Unlock()
+			if isMain {
+				redirects.add(e.Response.URL, int(e.Response.Status))
+			}
+		}
+	})
+
+	if err := chromedp.Run(cdpCtx, chromedp.Navigate(targetURL)); err != nil {
+		return nil, fmt.Errorf("chromedp failed: %w", err)
+	}
+	// Fail error pages like StaticFetcher does, so that both fetchers
+	// classify and retry them the same way.
+	mu.Lock()
+	documentStatus := status
+	mu.Unlock()
+	if documentStatus >= 400 {
+		return nil, &HTTPStatusError{StatusCode: documentStatus}
+	}
+
+	page := &Page{URL: targetURL}
+	if err := chromedp.Run(cdpCtx,
+		chromedp.Evaluate(`document.documentElement.outerHTML`, &page.HTML),
+		chromedp.Evaluate(`(function() {
+			if (document.doctype) {
+				return "<!DOCTYPE "
+					+ document.doctype.name
+					+ (document.doctype.publicId ? ' PUBLIC "' + document.doctype.publicId + '"' : '')
+					+ (document.doctype.systemId ? ' "' + document.doctype.systemId + '"' : '')
+					+ ">";
+			}
+			return "";
+		})()`, &page.Doctype),
+		chromedp.Title(&page.Title),
+	); err != nil {
+		return nil, fmt.Errorf("chromedp failed: %w", err)
+	}
+	page.Redirects = redirects.chain(maxRedirectHops())
+	if page.Redirects != nil {
+		page.URL = page.Redirects.FinalURL
+	}
+	return page, nil
+}
+
+// HTTPStatusError is returned by both fetchers for pages answered with an
+// error status.
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("page returned HTTP %d", e.StatusCode)
+}
+
+// StaticFetcher downloads the raw HTML with a plain HTTP GET. It needs no
+// browser but does not see content added by scripts.
+type StaticFetcher struct {
+	Client *http.Client
+}
+
+var rawDoctypeRegex = regexp.MustCompile(`(?is)^\s*(?:<!--.*?-->\s*)*(<!DOCTYPE[^>]*>)`)
+
+func (f *StaticFetcher) Fetch(ctx context.Context, targetURL string) (*Page, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("User-Agent", UserAgent())
+
+	redirects := newRedirectRecorder(targetURL)
+	resp, err := redirects.client(f.Client).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxStaticBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	page := &Page{
+		URL:       resp.Request.URL.String(),
+		HTML:      string(body),
+		Redirects: redirects.finish(resp, maxRedirectHops()),
+	}
+	if matches := rawDoctypeRegex.FindStringSubmatch(page.HTML); len(matches) > 1 {
+		page.Doctype = matches[1]
+	}
+	return page, nil
+}
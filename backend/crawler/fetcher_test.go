@@ -0,0 +1,74 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewFetcher(t *testing.T) {
+	if f, err := NewFetcher(FetcherStatic, nil); err != nil {
+		t.Fatal(err)
+	} else if _, ok := f.(*StaticFetcher); !ok {
+		t.Errorf("NewFetcher(%q) = %T, want *StaticFetcher", FetcherStatic, f)
+	}
+
+	t.Setenv("CRAWLER_FETCHER", "")
+	browser := NewBrowser()
+	if f, err := NewFetcher("", browser); err != nil {
+		t.Fatal(err)
+	} else if cf, ok := f.(ChromeFetcher); !ok || cf.Browser != browser {
+		t.Errorf("NewFetcher(\"\") = %#v, want a ChromeFetcher on the shared browser", f)
+	}
+
+	t.Setenv("CRAWLER_FETCHER", FetcherStatic)
+	if f, _ := NewFetcher("", nil); f == nil {
+		t.Error("CRAWLER_FETCHER is ignored")
+	} else if _, ok := f.(*StaticFetcher); !ok {
+		t.Errorf("NewFetcher with CRAWLER_FETCHER=static = %T, want *StaticFetcher", f)
+	}
+
+	if _, err := NewFetcher("firefox", nil); err == nil {
+		t.Error("an unknown fetcher is accepted")
+	}
+}
+
+func TestStaticFetcherCrawl(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<!-- generated -->
+<!DOCTYPE html>
+<html><head><title> Static page </title></head>
+<body><h1>One</h1><h2>Two</h2><h2>Three</h2>
+<form><input type="password" name="pw"></form>
+</body></html>`)
+	}))
+	defer srv.Close()
+
+	c := New(&StaticFetcher{Client: srv.Client()})
+	result, err := c.CrawlURL(context.Background(), srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Title != "Static page" {
+		t.Errorf("title = %q, want the <title> text", result.Title)
+	}
+	if result.HTMLVersion != "HTML" {
+		t.Errorf("HTML version = %q, want HTML from the doctype after the comment", result.HTMLVersion)
+	}
+	if result.Headings["h1"] != 1 || result.Headings["h2"] != 2 {
+		t.Errorf("headings = %v", result.Headings)
+	}
+	if !result.HasLoginForm {
+		t.Error("the password form is not detected")
+	}
+
+	if _, err := c.CrawlURL(context.Background(), srv.URL+"/missing"); err == nil {
+		t.Error("a 404 page is crawled without an error")
+	}
+}
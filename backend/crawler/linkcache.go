@@ -0,0 +1,152 @@
+package crawler
+
+import (
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+const (
+	DefaultLinkCacheTTL = time.Hour
+
+	maxLinkCacheEntries = 100000
+)
+
+// LinkCacheStore persists link checks so they survive restarts and can be
+// shared between processes.
+type LinkCacheStore interface {
+	// GetLinkCheck returns the latest check of url made at or after since.
+	GetLinkCheck(url string, since time.Time) (models.LinkCheck, bool, error)
+	PutLinkCheck(check models.LinkCheck) error
+}
+
+// LinkCacheStats reports how often cached link checks were reused.
+type LinkCacheStats struct {
+	Entries   int     `json:"entries"`
+	Hits      int64   `json:"hits"`
+	StoreHits int64   `json:"store_hits"`
+	Misses    int64   `json:"misses"`
+	HitRate   float64 `json:"hit_rate"`
+	TTL       string  `json:"ttl"`
+	Store     bool    `json:"store"`
+}
+
+// LinkCache keeps link checks in memory for TTL. On a memory miss it falls
+// back to Store, if one is set.
+type LinkCache struct {
+	TTL   time.Duration
+	Store LinkCacheStore
+
+	mu      sync.Mutex
+	entries map[string]models.LinkCheck
+
+	hits      atomic.Int64
+	storeHits atomic.Int64
+	misses    atomic.Int64
+}
+
+// NewLinkCache returns an empty cache. A zero ttl uses LINK_CACHE_TTL and
+// then DefaultLinkCacheTTL.
+func NewLinkCache(ttl time.Duration, store LinkCacheStore) *LinkCache {
+	if ttl <= 0 {
+		ttl = DefaultLinkCacheTTL
+		if v, err := time.ParseDuration(os.Getenv("LINK_CACHE_TTL")); err == nil && v > 0 {
+			ttl = v
+		}
+	}
+	return &LinkCache{
+		TTL:     ttl,
+		Store:   store,
+		entries: make(map[string]models.LinkCheck),
+	}
+}
+
+// Get returns a check of url that is younger than TTL.
+func (c *LinkCache) Get(url string) (models.LinkCheck, bool) {
+	since := time.Now().Add(-c.TTL)
+
+	c.mu.Lock()
+	check, ok := c.entries[url]
+	if ok && check.CheckedAt.Before(since) {
+		delete(c.entries, url)
+		ok = false
+	}
+	c.mu.Unlock()
+	if ok {
+		c.hits.Add(1)
+		return check, true
+	}
+
+	if c.Store != nil {
+		check, ok, err := c.Store.GetLinkCheck(url, since)
+		if err != nil {
+			log.Printf("Link cache store lookup failed: %v\n", err)
+		} else if ok {
+			c.storeHits.Add(1)
+			c.remember(check)
+			return check, true
+		}
+	}
+
+	c.misses.Add(1)
+	return models.LinkCheck{}, false
+}
+
+// Put records a check in memory and in Store.
+func (c *LinkCache) Put(check models.LinkCheck) {
+	if check.CheckedAt.IsZero() {
+		check.CheckedAt = time.Now()
+	}
+	c.remember(check)
+	if c.Store != nil {
+		if err := c.Store.PutLinkCheck(check); err != nil {
+			log.Printf("Link cache store write failed: %v\n", err)
+		}
+	}
+}
+
+func (c *LinkCache) remember(check models.LinkCheck) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxLinkCacheEntries {
+		c.evictLocked()
+	}
+	c.entries[check.URL] = check
+}
+
+// evictLocked drops expired entries, and everything if that is not enough.
+func (c *LinkCache) evictLocked() {
+	since := time.Now().Add(-c.TTL)
+	for url, check := range c.entries {
+		if check.CheckedAt.Before(since) {
+			delete(c.entries, url)
+		}
+	}
+	if len(c.entries) >= maxLinkCacheEntries {
+		c.entries = make(map[string]models.LinkCheck)
+	}
+}
+
+// Stats returns the current entry count and hit counters.
+func (c *LinkCache) Stats() LinkCacheStats {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+
+	stats := LinkCacheStats{
+		Entries:   entries,
+		Hits:      c.hits.Load(),
+		StoreHits: c.storeHits.Load(),
+		Misses:    c.misses.Load(),
+		TTL:       c.TTL.String(),
+		Store:     c.Store != nil,
+	}
+	if total := stats.Hits + stats.StoreHits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits+stats.StoreHits) / float64(total)
+	}
+	return stats
+}
@@ -0,0 +1,90 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+type fakeLinkCacheStore struct {
+	checks map[string]models.LinkCheck
+}
+
+func (s *fakeLinkCacheStore) GetLinkCheck(url string, since time.Time) (models.LinkCheck, bool, error) {
+	check, ok := s.checks[url]
+	if !ok || check.CheckedAt.Before(since) {
+		return models.LinkCheck{}, false, nil
+	}
+	return check, true, nil
+}
+
+func (s *fakeLinkCacheStore) PutLinkCheck(check models.LinkCheck) error {
+	s.checks[check.URL] = check
+	return nil
+}
+
+func TestLinkCacheTTL(t *testing.T) {
+	cache := NewLinkCache(time.Minute, nil)
+	cache.Put(models.LinkCheck{URL: "https://example.com/fresh", Broken: true, StatusCode: 404})
+	cache.Put(models.LinkCheck{URL: "https://example.com/stale", CheckedAt: time.Now().Add(-time.Hour)})
+
+	if check, ok := cache.Get("https://example.com/fresh"); !ok || check.StatusCode != 404 {
+		t.Errorf("fresh entry = %+v, %v; want the cached 404", check, ok)
+	}
+	if _, ok := cache.Get("https://example.com/stale"); ok {
+		t.Error("an entry older than the TTL is returned")
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 || stats.HitRate != 0.5 {
+		t.Errorf("stats = %+v, want 1 hit, 1 miss and the stale entry dropped", stats)
+	}
+}
+
+func TestLinkCacheStoreFallback(t *testing.T) {
+	store := &fakeLinkCacheStore{checks: map[string]models.LinkCheck{
+		"https://example.com/a": {URL: "https://example.com/a", StatusCode: 500, Broken: true, CheckedAt: time.Now()},
+	}}
+	cache := NewLinkCache(time.Minute, store)
+
+	if check, ok := cache.Get("https://example.com/a"); !ok || check.StatusCode != 500 {
+		t.Errorf("store entry = %+v, %v; want the stored 500", check, ok)
+	}
+	if _, ok := cache.Get("https://example.com/a"); !ok {
+		t.Error("the store hit is not kept in memory")
+	}
+	if stats := cache.Stats(); stats.StoreHits != 1 || stats.Hits != 1 {
+		t.Errorf("stats = %+v, want 1 store hit then 1 memory hit", stats)
+	}
+
+	cache.Put(models.LinkCheck{URL: "https://example.com/b"})
+	if _, ok := store.checks["https://example.com/b"]; !ok {
+		t.Error("Put does not write through to the store")
+	}
+}
+
+func TestLinkCheckerUsesCache(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	lc := NewLinkChecker(2, 2)
+	lc.Cache = NewLinkCache(time.Minute, nil)
+	links := parseLinkURLs(t, srv.URL+"/missing")
+	for i := 0; i < 3; i++ {
+		if check := lc.CheckAll(context.Background(), links)[srv.URL+"/missing"]; check.StatusCode != http.StatusNotFound {
+			t.Fatalf("check %d = %+v, want a 404", i, check)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("the link was requested %d times, want once", n)
+	}
+}
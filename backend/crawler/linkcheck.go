@@ -0,0 +1,309 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+const (
+	DefaultLinkWorkers = 16
+	DefaultLinkPerHost = 4
+	MaxLinkWorkers     = 64
+
+	maxRateLimitRetries = 2
+	defaultRetryAfter   = 2 * time.Second
+	maxRetryAfter       = 30 * time.Second
+)
+
+// LinkChecker verifies links over a bounded pool of workers. At most PerHost
+// requests run against the same host at any time, and every request waits for
+// Limiter. Results found in Cache are reused instead of requesting the link
+// again.
+type LinkChecker struct {
+	Client  *http.Client
+	Workers int
+	PerHost int
+	Cache   *LinkCache
+	Limiter Limiters
+	// Hosts, if set, also bounds the requests per host of every checker
+	// sharing it, e.g. all crawls of a worker pool.
+	Hosts *HostSlots
+
+	slots *HostSlots // the checker's own PerHost bound
+}
+
+// NewLinkChecker returns a checker with the given limits. Zero values fall
+// back to LINK_CHECK_WORKERS / LINK_CHECK_PER_HOST and then to the defaults.
+func NewLinkChecker(workers, perHost int) *LinkChecker {
+	if workers <= 0 {
+		workers = envInt("LINK_CHECK_WORKERS", DefaultLinkWorkers)
+	}
+	if workers > MaxLinkWorkers {
+		workers = MaxLinkWorkers
+	}
+	if perHost <= 0 {
+		perHost = envInt("LINK_CHECK_PER_HOST", DefaultLinkPerHost)
+	}
+	if perHost > workers {
+		perHost = workers
+	}
+	return &LinkChecker{
+		Client:  &http.Client{Timeout: 10 * time.Second},
+		Workers: workers,
+		PerHost: perHost,
+		slots:   NewHostSlots(perHost),
+	}
+}
+
+// CheckAll checks every URL once and returns the status of each. It returns
+// early with a partial map if ctx is canceled.
+func (lc *LinkChecker) CheckAll(ctx context.Context, links []*url.URL) map[string]models.LinkCheck {
+	return lc.CheckAllProgress(ctx, links, nil)
+}
+
+// CheckAllProgress is CheckAll, calling progress, if not nil, with the number
+// of links checked so far after each check.
+func (lc *LinkChecker) CheckAllProgress(ctx context.Context, links []*url.URL, progress func(done int)) map[string]models.LinkCheck {
+	results := make(map[string]models.LinkCheck, len(links))
+	if len(links) == 0 {
+		return results
+	}
+
+	var mu sync.Mutex
+	jobs := make(chan *url.URL)
+	var wg sync.WaitGroup
+
+	workers := lc.Workers
+	if workers > len(links) {
+		workers = len(links)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for link := range jobs {
+				status := lc.check(ctx, link)
+				mu.Lock()
+				results[link.String()] = status
+				done := len(results)
+				mu.Unlock()
+				if progress != nil {
+					progress(done)
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, link := range links {
+		select {
+		case jobs <- link:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// check returns the cached status of link or requests it.
+func (lc *LinkChecker) check(ctx context.Context, link *url.URL) models.LinkCheck {
+	if link.Scheme != "http" && link.Scheme != "https" {
+		return models.LinkCheck{URL: link.String()}
+	}
+	if lc.Cache != nil {
+		if check, ok := lc.Cache.Get(link.String()); ok {
+			return check
+		}
+	}
+
+	check := lc.request(ctx, link)
+	check.URL = link.String()
+	check.CheckedAt = time.Now()
+	// A request cut short by cancellation or rate limiting says nothing
+	// lasting about the link.
+	if lc.Cache != nil && ctx.Err() == nil && check.Reason != models.ReasonRateLimited {
+		lc.Cache.Put(check)
+	}
+	return check
+}
+
+// request checks link while holding a slot for its host. It starts with a
+// HEAD request and falls back to a one-byte ranged GET for servers that
+// reject HEAD. 429 responses are retried after their Retry-After delay.
+// Redirects are followed and recorded.
+func (lc *LinkChecker) request(ctx context.Context, link *url.URL) models.LinkCheck {
+	for _, slots := range []*HostSlots{lc.slots, lc.Hosts} {
+		if slots == nil {
+			continue
+		}
+		release := slots.acquire(ctx, link.Hostname())
+		if release == nil {
+			return models.LinkCheck{}
+		}
+		defer release()
+	}
+
+	maxHops := maxRedirectHops()
+	for attempt := 0; ; attempt++ {
+		status, retryAfter, chain, err := lc.do(ctx, http.MethodHead, link, maxHops)
+		if err != nil && classifyError(err) == models.ReasonNetwork || headRejected(status) {
+			status, retryAfter, chain, err = lc.do(ctx, http.MethodGet, link, maxHops)
+		}
+
+		switch {
+		case err != nil:
+			return models.LinkCheck{Broken: true, Reason: classifyError(err), Redirects: chain}
+		case status == http.StatusTooManyRequests:
+			if attempt >= maxRateLimitRetries || retryAfter > maxRetryAfter {
+				return models.LinkCheck{Broken: true, StatusCode: status, Reason: models.ReasonRateLimited, Redirects: chain}
+			}
+			select {
+			case <-time.After(retryAfter):
+			case <-ctx.Done():
+				return models.LinkCheck{}
+			}
+		case status >= 400:
+			return models.LinkCheck{Broken: true, StatusCode: status, Reason: models.ReasonHTTPStatus, Redirects: chain}
+		default:
+			return models.LinkCheck{StatusCode: status, Redirects: chain}
+		}
+	}
+}
+
+// do sends one request and returns the status code, the Retry-After delay
+// and the redirect chain followed, if any.
+func (lc *LinkChecker) do(ctx context.Context, method string, link *url.URL, maxHops int) (int, time.Duration, *models.RedirectChain, error) {
+	if err := lc.Limiter.Wait(ctx, link.Hostname()); err != nil {
+		return 0, 0, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, link.String(), nil)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent())
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	redirects := newRedirectRecorder(link.String())
+	resp, err := redirects.client(lc.Client).Do(req)
+	if err != nil {
+		return 0, 0, redirects.chain(maxHops), err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	return resp.StatusCode, parseRetryAfter(resp.Header.Get("Retry-After")), redirects.finish(resp, maxHops), nil
+}
+
+// headRejected reports whether a HEAD status likely means the server does not
+// support HEAD rather than that the link is broken.
+func headRejected(status int) bool {
+	switch status {
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusMethodNotAllowed,
+		http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as a date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return defaultRetryAfter
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return defaultRetryAfter
+}
+
+// classifyError maps a request error to a broken link reason.
+func classifyError(err error) string {
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var hostErr x509.HostnameError
+	var authorityErr x509.UnknownAuthorityError
+	var invalidErr x509.CertificateInvalidError
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return models.ReasonDNS
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &hostErr),
+		errors.As(err, &authorityErr), errors.As(err, &invalidErr):
+		return models.ReasonTLS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return models.ReasonConnectionRefused
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return models.ReasonTimeout
+	default:
+		return models.ReasonNetwork
+	}
+}
+
+// HostSlots bounds the concurrent requests to each host across the link
+// checkers sharing it.
+type HostSlots struct {
+	perHost int
+
+	mu    sync.Mutex
+	hosts map[string]chan struct{}
+}
+
+// NewHostSlots allows perHost concurrent requests to each host. Zero falls
+// back to LINK_CHECK_PER_HOST and then to DefaultLinkPerHost.
+func NewHostSlots(perHost int) *HostSlots {
+	if perHost <= 0 {
+		perHost = envInt("LINK_CHECK_PER_HOST", DefaultLinkPerHost)
+	}
+	return &HostSlots{perHost: perHost, hosts: make(map[string]chan struct{})}
+}
+
+// acquire waits for a free slot for host and returns the function that
+// frees it, or nil if ctx is done first.
+func (h *HostSlots) acquire(ctx context.Context, host string) func() {
+	h.mu.Lock()
+	slot, ok := h.hosts[host]
+	if !ok {
+		slot = make(chan struct{}, h.perHost)
+		h.hosts[host] = slot
+	}
+	h.mu.Unlock()
+
+	select {
+	case slot <- struct{}{}:
+		return func() { <-slot }
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// envInt reads a positive integer from the environment.
+func envInt(key string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,217 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+func parseLinkURLs(t *testing.T, raws ...string) []*url.URL {
+	t.Helper()
+	links := make([]*url.URL, len(raws))
+	for i, raw := range raws {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		links[i] = u
+	}
+	return links
+}
+
+func TestNewLinkCheckerLimits(t *testing.T) {
+	lc := NewLinkChecker(MaxLinkWorkers+10, MaxLinkWorkers+20)
+	if lc.Workers != MaxLinkWorkers || lc.PerHost != MaxLinkWorkers {
+		t.Errorf("limits = %d workers, %d per host; want both capped at %d", lc.Workers, lc.PerHost, MaxLinkWorkers)
+	}
+
+	t.Setenv("LINK_CHECK_WORKERS", "3")
+	t.Setenv("LINK_CHECK_PER_HOST", "2")
+	if lc := NewLinkChecker(0, 0); lc.Workers != 3 || lc.PerHost != 2 {
+		t.Errorf("limits from the environment = %d workers, %d per host; want 3 and 2", lc.Workers, lc.PerHost)
+	}
+}
+
+func TestLinkCheckerPerHostLimit(t *testing.T) {
+	var (
+		mu               sync.Mutex
+		inFlight, peak   int
+		perHost, workers = 2, 8
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	var raws []string
+	for i := 0; i < 12; i++ {
+		raws = append(raws, fmt.Sprintf("%s/page%d", srv.URL, i))
+	}
+	lc := NewLinkChecker(workers, perHost)
+	statuses := lc.CheckAll(context.Background(), parseLinkURLs(t, raws...))
+
+	if len(statuses) != len(raws) {
+		t.Errorf("checked %d links, want %d", len(statuses), len(raws))
+	}
+	if peak > perHost {
+		t.Errorf("%d requests ran against one host at once, want at most %d", peak, perHost)
+	}
+}
+
+func TestLinkCheckerSharedHosts(t *testing.T) {
+	var (
+		mu             sync.Mutex
+		inFlight, peak int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	// Two crawls with a per-host cap of 4 each stay within the shared cap.
+	shared := NewHostSlots(2)
+	var wg sync.WaitGroup
+	for c := 0; c < 2; c++ {
+		var raws []string
+		for i := 0; i < 8; i++ {
+			raws = append(raws, fmt.Sprintf("%s/crawl%d/page%d", srv.URL, c, i))
+		}
+		links := parseLinkURLs(t, raws...)
+		lc := NewLinkChecker(8, 4)
+		lc.Hosts = shared
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lc.CheckAll(context.Background(), links)
+		}()
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("%d requests ran against one host at once, want at most the shared 2", peak)
+	}
+}
+
+func TestLinkCheckerCheckAll(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+		}
+	}))
+	defer srv.Close()
+
+	links := parseLinkURLs(t, srv.URL+"/ok", srv.URL+"/gone", "http://127.0.0.1:1/", "mailto:me@example.com")
+	statuses := NewLinkChecker(4, 2).CheckAll(context.Background(), links)
+
+	want := map[string]models.LinkCheck{
+		srv.URL + "/ok":         {StatusCode: http.StatusOK},
+		srv.URL + "/gone":       {Broken: true, StatusCode: http.StatusGone, Reason: models.ReasonHTTPStatus},
+		"http://127.0.0.1:1/":   {Broken: true, Reason: models.ReasonConnectionRefused},
+		"mailto:me@example.com": {},
+	}
+	for link, w := range want {
+		got, ok := statuses[link]
+		if !ok || got.Broken != w.Broken || got.StatusCode != w.StatusCode || got.Reason != w.Reason {
+			t.Errorf("%s: got %+v (checked %v), want %+v", link, got, ok, w)
+		}
+	}
+}
+
+func TestLinkCheckerCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	links := parseLinkURLs(t, "http://127.0.0.1:1/a", "http://127.0.0.1:1/b")
+	for link, status := range NewLinkChecker(1, 1).CheckAll(ctx, links) {
+		if status.Broken {
+			t.Errorf("%s reported broken after the crawl was canceled", link)
+		}
+	}
+}
+
+func TestLinkCheckerHeadFallback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Range") != "bytes=0-0" {
+			t.Errorf("fallback GET sent Range %q, want bytes=0-0", r.Header.Get("Range"))
+		}
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusPartialContent)
+	}))
+	defer srv.Close()
+
+	statuses := NewLinkChecker(2, 2).CheckAll(context.Background(), parseLinkURLs(t, srv.URL+"/ok", srv.URL+"/missing"))
+	if got := statuses[srv.URL+"/ok"]; got.Broken || got.StatusCode != http.StatusPartialContent {
+		t.Errorf("a page rejecting HEAD = %+v, want it checked with GET", got)
+	}
+	if got := statuses[srv.URL+"/missing"]; !got.Broken || got.StatusCode != http.StatusNotFound {
+		t.Errorf("a missing page rejecting HEAD = %+v, want a broken 404", got)
+	}
+}
+
+func TestLinkCheckerRateLimited(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/busy" {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	statuses := NewLinkChecker(1, 1).CheckAll(context.Background(), parseLinkURLs(t, srv.URL+"/"))
+	if got := statuses[srv.URL+"/"]; got.Broken || requests != 2 {
+		t.Errorf("got %+v after %d requests, want the 429 retried once", got, requests)
+	}
+
+	statuses = NewLinkChecker(1, 1).CheckAll(context.Background(), parseLinkURLs(t, srv.URL+"/busy"))
+	if got := statuses[srv.URL+"/busy"]; got.Reason != models.ReasonRateLimited {
+		t.Errorf("a long Retry-After gave %+v, want reason %q", got, models.ReasonRateLimited)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	for value, want := range map[string]time.Duration{
+		"":                              defaultRetryAfter,
+		"5":                             5 * time.Second,
+		"soon":                          defaultRetryAfter,
+		"-1":                            defaultRetryAfter,
+		"Mon, 01 Jan 2001 00:00:00 GMT": 0,
+	} {
+		if got := parseRetryAfter(value); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package crawler
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultHostRate  = 8.0
+	DefaultHostBurst = 16
+
+	maxIdleBuckets = 1000
+)
+
+// HostThrottle is the state of one host's token bucket.
+type HostThrottle struct {
+	Rate        float64   `json:"rate"`
+	Burst       int       `json:"burst"`
+	Tokens      float64   `json:"tokens"`
+	Waiting     int       `json:"waiting"`
+	Throttled   int64     `json:"throttled"`
+	LastRequest time.Time `json:"last_request"`
+}
+
+type bucket struct {
+	tokens      float64
+	last        time.Time
+	lastRequest time.Time
+	waiting     int
+	throttled   int64
+}
+
+// HostLimiter allows Rate requests per second to each host, with bursts of
+// up to Burst requests.
+type HostLimiter struct {
+	Rate  float64
+	Burst int
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// NewHostLimiter returns a limiter. Zero values fall back to HOST_RATE_LIMIT /
+// HOST_RATE_BURST and then to the defaults.
+func NewHostLimiter(rate float64, burst int) *HostLimiter {
+	if rate <= 0 {
+		rate = DefaultHostRate
+		if v, err := strconv.ParseFloat(os.Getenv("HOST_RATE_LIMIT"), 64); err == nil && v > 0 {
+			rate = v
+		}
+	}
+	if burst <= 0 {
+		burst = envInt("HOST_RATE_BURST", DefaultHostBurst)
+	}
+	return &HostLimiter{Rate: rate, Burst: burst, buckets: make(map[string]*bucket)}
+}
+
+// Wait blocks until a request to host is allowed or ctx is done.
+func (l *HostLimiter) Wait(ctx context.Context, host string) error {
+	host = strings.ToLower(host)
+
+	l.mu.Lock()
+	now := time.Now()
+	b, ok := l.buckets[host]
+	if !ok {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.pruneLocked(now)
+		}
+		b = &bucket{tokens: float64(l.Burst), last: now}
+		l.buckets[host] = b
+	}
+	l.refillLocked(b, now)
+	b.lastRequest = now
+	// Take the token now, possibly going negative; the debt is the wait.
+	b.tokens--
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / l.Rate * float64(time.Second))
+		b.waiting++
+		b.throttled++
+	}
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		l.mu.Lock()
+		b.waiting--
+		l.mu.Unlock()
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		b.waiting--
+		b.tokens++ // give the unused token back
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (l *HostLimiter) refillLocked(b *bucket, now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * l.Rate
+	if b.tokens > float64(l.Burst) {
+		b.tokens = float64(l.Burst)
+	}
+	b.last = now
+}
+
+// pruneLocked drops buckets that are full again and have no waiters.
+func (l *HostLimiter) pruneLocked(now time.Time) {
+	for host, b := range l.buckets {
+		l.refillLocked(b, now)
+		if b.waiting == 0 && b.tokens >= float64(l.Burst) {
+			delete(l.buckets, host)
+		}
+	}
+}
+
+// Snapshot returns the throttle state of every host seen recently.
+func (l *HostLimiter) Snapshot() map[string]HostThrottle {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	snapshot := make(map[string]HostThrottle, len(l.buckets))
+	for host, b := range l.buckets {
+		l.refillLocked(b, now)
+		snapshot[host] = HostThrottle{
+			Rate:        l.Rate,
+			Burst:       l.Burst,
+			Tokens:      b.tokens,
+			Waiting:     b.waiting,
+			Throttled:   b.throttled,
+			LastRequest: b.lastRequest,
+		}
+	}
+	return snapshot
+}
+
+// Limiters applies several HostLimiters in turn, e.g. the global one and
+// the stricter limit of a single crawl.
+type Limiters []*HostLimiter
+
+// Wait blocks until every limiter allows a request to host.
+func (ls Limiters) Wait(ctx context.Context, host string) error {
+	for _, l := range ls {
+		if l == nil {
+			continue
+		}
+		if err := l.Wait(ctx, host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHostLimiterBurstAndRate(t *testing.T) {
+	l := NewHostLimiter(20, 2)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := l.Wait(ctx, "Example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Two requests fit in the burst, the other two wait 50ms each.
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("4 requests took %v, want about 100ms at 20/s with a burst of 2", elapsed)
+	}
+
+	if err := l.Wait(ctx, "other.example"); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := l.Snapshot()
+	if got := snapshot["example.com"].Throttled; got != 2 {
+		t.Errorf("example.com throttled %d times, want 2", got)
+	}
+	if got := snapshot["other.example"].Throttled; got != 0 {
+		t.Errorf("other.example throttled %d times, want its own bucket", got)
+	}
+}
+
+func TestHostLimiterCanceled(t *testing.T) {
+	l := NewHostLimiter(0.1, 1)
+	if err := l.Wait(context.Background(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, "example.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait = %v, want the context error", err)
+	}
+	if state := l.Snapshot()["example.com"]; state.Waiting != 0 || state.Tokens < -0.01 {
+		t.Errorf("after cancel the bucket is %+v, want the token returned", state)
+	}
+}
+
+func TestLimitersSkipNil(t *testing.T) {
+	if err := (Limiters{nil, NewHostLimiter(1, 1)}).Wait(context.Background(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,124 @@
+package crawler
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+
+	"scrawling_dashboard/backend/models"
+)
+
+const (
+	// DefaultMaxRedirectHops is the chain length above which a redirect chain
+	// is flagged as too long.
+	DefaultMaxRedirectHops = 5
+
+	// maxFollowedRedirects stops following a chain, like http.Client does.
+	maxFollowedRedirects = 10
+)
+
+// redirectRecorder collects the responses of a redirect chain.
+type redirectRecorder struct {
+	mu    sync.Mutex
+	start string
+	hops  []models.RedirectHop
+	loop  bool
+}
+
+func newRedirectRecorder(start string) *redirectRecorder {
+	return &redirectRecorder{start: start}
+}
+
+// add records a response in the chain.
+func (r *redirectRecorder) add(url string, status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hops = append(r.hops, models.RedirectHop{URL: url, StatusCode: status})
+}
+
+// checkRedirect is an http.Client CheckRedirect hook. It records each
+// redirect response and stops at loops and overly long chains, leaving the
+// last redirect response as the result.
+func (r *redirectRecorder) checkRedirect(req *http.Request, via []*http.Request) error {
+	if prev := req.Response; prev != nil {
+		r.add(prev.Request.URL.String(), prev.StatusCode)
+	}
+	next := req.URL.String()
+	for _, v := range via {
+		if v.URL.String() == next {
+			r.mu.Lock()
+			r.loop = true
+			r.mu.Unlock()
+			return http.ErrUseLastResponse
+		}
+	}
+	if len(via) >= maxFollowedRedirects {
+		return http.ErrUseLastResponse
+	}
+	return nil
+}
+
+// client returns a copy of base that records redirects into r.
+func (r *redirectRecorder) client(base *http.Client) *http.Client {
+	c := *base
+	c.CheckRedirect = r.checkRedirect
+	return &c
+}
+
+// finish adds the final response and returns the chain, or nil if there
+// were no redirects. The final response is skipped when the chain was cut
+// short, since it is then the last recorded redirect.
+func (r *redirectRecorder) finish(resp *http.Response, maxHops int) *models.RedirectChain {
+	if resp != nil {
+		r.mu.Lock()
+		n := len(r.hops)
+		last := resp.Request.URL.String()
+		cut := n > 0 && r.hops[n-1].URL == last && r.hops[n-1].StatusCode == resp.StatusCode
+		r.mu.Unlock()
+		if !cut {
+			r.add(last, resp.StatusCode)
+		}
+	}
+	return r.chain(maxHops)
+}
+
+// chain builds the RedirectChain and its flags from the recorded hops.
+func (r *redirectRecorder) chain(maxHops int) *models.RedirectChain {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.hops) < 2 {
+		return nil
+	}
+
+	chain := &models.RedirectChain{
+		URL:      r.start,
+		Hops:     append([]models.RedirectHop(nil), r.hops...),
+		FinalURL: r.hops[len(r.hops)-1].URL,
+		Loop:     r.loop,
+	}
+	seen := map[string]bool{}
+	for i, hop := range chain.Hops {
+		if seen[hop.URL] {
+			chain.Loop = true
+		}
+		seen[hop.URL] = true
+		if i > 0 && isDowngrade(chain.Hops[i-1].URL, hop.URL) {
+			chain.Downgrade = true
+		}
+	}
+	redirects := len(chain.Hops) - 1
+	chain.TooLong = redirects > maxHops || redirects >= maxFollowedRedirects
+	return chain
+}
+
+// isDowngrade reports whether a redirect goes from HTTPS to plain HTTP.
+func isDowngrade(from, to string) bool {
+	f, err1 := url.Parse(from)
+	t, err2 := url.Parse(to)
+	return err1 == nil && err2 == nil && f.Scheme == "https" && t.Scheme == "http"
+}
+
+// maxRedirectHops returns MAX_REDIRECT_HOPS or DefaultMaxRedirectHops.
+func maxRedirectHops() int {
+	return envInt("MAX_REDIRECT_HOPS", DefaultMaxRedirectHops)
+}
@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"scrawling_dashboard/backend/models"
+)
+
+func TestCrawlRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/start":
+			http.Redirect(w, r, "/mid", http.StatusMovedPermanently)
+		case "/mid":
+			http.Redirect(w, r, "/page", http.StatusFound)
+		case "/loop1":
+			http.Redirect(w, r, "/loop2", http.StatusFound)
+		case "/loop2":
+			http.Redirect(w, r, "/loop1", http.StatusFound)
+		case "/page":
+			fmt.Fprint(w, `<html><a href="/loop1">loop</a><a href="/start">start</a><a href="/page">self</a></html>`)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(&StaticFetcher{Client: &http.Client{}})
+	result, err := c.CrawlURL(context.Background(), srv.URL+"/start")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []models.RedirectHop{
+		{URL: srv.URL + "/start", StatusCode: http.StatusMovedPermanently},
+		{URL: srv.URL + "/mid", StatusCode: http.StatusFound},
+		{URL: srv.URL + "/page", StatusCode: http.StatusOK},
+	}
+	if result.Redirects == nil || !reflect.DeepEqual(result.Redirects.Hops, want) {
+		t.Fatalf("page redirects = %+v, want hops %+v", result.Redirects, want)
+	}
+	if result.Redirects.FinalURL != srv.URL+"/page" || result.Redirects.Loop {
+		t.Errorf("page redirects = %+v", result.Redirects)
+	}
+
+	chains := make(map[string]models.RedirectChain)
+	for _, chain := range result.LinkRedirects {
+		chains[chain.URL] = chain
+	}
+	if len(chains) != 2 {
+		t.Fatalf("link redirects = %+v, want /loop1 and /start", result.LinkRedirects)
+	}
+	if loop := chains[srv.URL+"/loop1"]; !loop.Loop {
+		t.Errorf("/loop1 chain = %+v, want a loop", loop)
+	}
+	if start := chains[srv.URL+"/start"]; start.Loop || len(start.Hops) != 3 {
+		t.Errorf("/start chain = %+v, want 3 hops", start)
+	}
+}
+
+func TestLinksResolveAfterRedirect(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/dir/page", http.StatusMovedPermanently)
+		case "/dir/page":
+			fmt.Fprint(w, `<html><a href="sub">sub</a></html>`)
+		case "/dir/sub":
+			fmt.Fprint(w, `<html></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(&StaticFetcher{Client: &http.Client{}})
+	result, err := c.CrawlURL(context.Background(), srv.URL+"/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Links) != 1 || result.Links[0].URL != srv.URL+"/dir/sub" || result.Links[0].Broken {
+		t.Errorf("links = %+v, want sub resolved against /dir/page", result.Links)
+	}
+
+	var crawled []string
+	err = c.CrawlSite(context.Background(), srv.URL+"/old", models.SiteOptions{MaxDepth: 1}, func(page *models.CrawlResult) error {
+		crawled = append(crawled, page.URL)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{srv.URL + "/old", srv.URL + "/dir/sub"}; !reflect.DeepEqual(crawled, want) {
+		t.Errorf("site crawl visited %v, want %v", crawled, want)
+	}
+}
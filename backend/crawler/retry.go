@@ -0,0 +1,189 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+// Error classes of failed crawls, used to decide whether to retry.
+const (
+	ErrorTimeout           = "timeout"
+	ErrorNavigation        = "navigation"
+	ErrorDNS               = "dns"
+	ErrorTLS               = "tls"
+	ErrorConnectionRefused = "connection_refused"
+	ErrorNetwork           = "network"
+	ErrorServer            = "http_5xx"
+	ErrorRateLimited       = "http_429"
+	ErrorClient            = "http_4xx"
+	ErrorBlocked           = "blocked"
+	ErrorCanceled          = "canceled"
+	ErrorStore             = "store"
+	ErrorOther             = "other"
+)
+
+// ErrNotStored marks the errors of crawls whose results could not be
+// written to the database.
+var ErrNotStored = errors.New("result not stored")
+
+const (
+	DefaultMaxAttempts = 3
+	DefaultRetryBase   = 5 * time.Second
+	DefaultRetryMax    = 5 * time.Minute
+)
+
+// DefaultRetryOn lists the error classes retried by default: the ones that
+// tend to go away on their own.
+var DefaultRetryOn = []string{
+	ErrorTimeout, ErrorNavigation, ErrorConnectionRefused, ErrorNetwork,
+	ErrorServer, ErrorRateLimited, ErrorStore,
+}
+
+// chromeNetErrors maps Chrome's net::ERR_ codes to error classes. Codes not
+// listed count as navigation errors.
+var chromeNetErrors = map[string]string{
+	"net::ERR_NAME_NOT_RESOLVED":      ErrorDNS,
+	"net::ERR_NAME_RESOLUTION_FAILED": ErrorDNS,
+	"net::ERR_CONNECTION_REFUSED":     ErrorConnectionRefused,
+	"net::ERR_TIMED_OUT":              ErrorTimeout,
+	"net::ERR_CONNECTION_TIMED_OUT":   ErrorTimeout,
+	"net::ERR_CONNECTION_RESET":       ErrorNetwork,
+	"net::ERR_CONNECTION_CLOSED":      ErrorNetwork,
+	"net::ERR_INTERNET_DISCONNECTED":  ErrorNetwork,
+	"net::ERR_CERT_":                  ErrorTLS,
+	"net::ERR_SSL_":                   ErrorTLS,
+}
+
+// ErrorClass returns the class of a crawl error.
+func ErrorClass(err error) string {
+	var statusErr *HTTPStatusError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrBlockedByRobots):
+		return ErrorBlocked
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case errors.Is(err, ErrNotStored):
+		return ErrorStore
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTimeout
+	case errors.As(err, &statusErr):
+		switch {
+		case statusErr.StatusCode == 429:
+			return ErrorRateLimited
+		case statusErr.StatusCode >= 500:
+			return ErrorServer
+		default:
+			return ErrorClient
+		}
+	}
+
+	msg := err.Error()
+	if i := strings.Index(msg, "net::ERR_"); i >= 0 {
+		code := msg[i:]
+		for prefix, class := range chromeNetErrors {
+			if strings.HasPrefix(code, prefix) {
+				return class
+			}
+		}
+		return ErrorNavigation
+	}
+	if strings.Contains(msg, "chromedp") {
+		return ErrorNavigation
+	}
+
+	// Errors of the static fetcher's HTTP client.
+	switch classifyError(err) {
+	case models.ReasonDNS:
+		return ErrorDNS
+	case models.ReasonTLS:
+		return ErrorTLS
+	case models.ReasonConnectionRefused:
+		return ErrorConnectionRefused
+	case models.ReasonTimeout:
+		return ErrorTimeout
+	}
+	if strings.HasPrefix(msg, "request failed") || strings.HasPrefix(msg, "failed to read body") {
+		return ErrorNetwork
+	}
+	return ErrorOther
+}
+
+// RetryPolicy decides whether and when a failed crawl is tried again.
+// Attempt n (counting from 1) is followed by a delay of BaseDelay*2^(n-1),
+// capped at MaxDelay, of which a random fraction Jitter is taken off.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64
+	RetryOn     []string
+}
+
+// NewRetryPolicy returns the policy configured by CRAWL_MAX_ATTEMPTS,
+// CRAWL_RETRY_BASE, CRAWL_RETRY_MAX and CRAWL_RETRY_ON (a comma-separated
+// list of error classes), falling back to the defaults.
+func NewRetryPolicy() RetryPolicy {
+	p := RetryPolicy{
+		MaxAttempts: envInt("CRAWL_MAX_ATTEMPTS", DefaultMaxAttempts),
+		BaseDelay:   envDuration("CRAWL_RETRY_BASE", DefaultRetryBase),
+		MaxDelay:    envDuration("CRAWL_RETRY_MAX", DefaultRetryMax),
+		Jitter:      0.5,
+		RetryOn:     DefaultRetryOn,
+	}
+	if v := os.Getenv("CRAWL_RETRY_ON"); v != "" {
+		p.RetryOn = nil
+		for _, class := range strings.Split(v, ",") {
+			if class = strings.TrimSpace(class); class != "" {
+				p.RetryOn = append(p.RetryOn, class)
+			}
+		}
+	}
+	return p
+}
+
+// Retryable reports whether errors of class are retried.
+func (p RetryPolicy) Retryable(class string) bool {
+	for _, c := range p.RetryOn {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// ShouldRetry reports whether a crawl that failed with err on its
+// attempt-th attempt gets another one.
+func (p RetryPolicy) ShouldRetry(err error, attempt int) bool {
+	return err != nil && attempt < p.MaxAttempts && p.Retryable(ErrorClass(err))
+}
+
+// Backoff returns the delay after the attempt-th attempt.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		delay -= time.Duration(rand.Float64() * p.Jitter * float64(delay))
+	}
+	return delay
+}
+
+// envDuration reads a positive duration such as "30s" from the environment.
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
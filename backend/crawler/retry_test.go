@@ -0,0 +1,74 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{fmt.Errorf("chromedp failed: %w", context.DeadlineExceeded), ErrorTimeout},
+		{errors.New("chromedp failed: page load error net::ERR_NAME_NOT_RESOLVED"), ErrorDNS},
+		{errors.New("chromedp failed: page load error net::ERR_CONNECTION_REFUSED"), ErrorConnectionRefused},
+		{errors.New("chromedp failed: page load error net::ERR_CERT_DATE_INVALID"), ErrorTLS},
+		{errors.New("chromedp failed: page load error net::ERR_ABORTED"), ErrorNavigation},
+		{&HTTPStatusError{StatusCode: 503}, ErrorServer},
+		{&HTTPStatusError{StatusCode: 429}, ErrorRateLimited},
+		{&HTTPStatusError{StatusCode: 404}, ErrorClient},
+		{fmt.Errorf("crawl canceled: %w", context.Canceled), ErrorCanceled},
+		{ErrBlockedByRobots, ErrorBlocked},
+		{fmt.Errorf("%w: disk full", ErrNotStored), ErrorStore},
+		{errors.New("something else"), ErrorOther},
+	}
+	for _, tt := range tests {
+		if got := ErrorClass(tt.err); got != tt.want {
+			t.Errorf("ErrorClass(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestErrorClassStaticFetcher(t *testing.T) {
+	f := &StaticFetcher{Client: &http.Client{Timeout: 5 * time.Second}}
+	_, err := f.Fetch(context.Background(), "http://127.0.0.1:1/")
+	if got := ErrorClass(err); got != ErrorConnectionRefused {
+		t.Errorf("ErrorClass(%v) = %q, want %q", err, got, ErrorConnectionRefused)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	p := RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Second,
+		MaxDelay:    5 * time.Second,
+		Jitter:      0.5,
+		RetryOn:     DefaultRetryOn,
+	}
+
+	server := &HTTPStatusError{StatusCode: 502}
+	if !p.ShouldRetry(server, 1) || !p.ShouldRetry(server, 2) {
+		t.Error("server errors are not retried")
+	}
+	if p.ShouldRetry(server, 3) {
+		t.Error("retried after the last attempt")
+	}
+	if p.ShouldRetry(&HTTPStatusError{StatusCode: 404}, 1) {
+		t.Error("client errors are retried")
+	}
+	if p.ShouldRetry(fmt.Errorf("crawl canceled: %w", context.Canceled), 1) {
+		t.Error("canceled crawls are retried")
+	}
+
+	for attempt, max := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if d := p.Backoff(attempt); d < max/2 || d > max {
+			t.Errorf("Backoff(%d) = %v, want between %v and %v", attempt, d, max/2, max)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+)
+
+const (
+	DefaultRobotsAgent = "ScrawlingDashboard"
+
+	robotsTTL       = time.Hour
+	robotsErrorTTL  = 5 * time.Minute
+	maxCrawlDelay   = time.Minute
+	maxRobotsTxtLen = 512 << 10
+)
+
+// ErrBlockedByRobots is returned for pages disallowed by robots.txt.
+var ErrBlockedByRobots = errors.New("blocked by robots.txt")
+
+// RobotsAgent returns the user agent token matched against robots.txt
+// groups, taken from ROBOTS_USER_AGENT if set.
+func RobotsAgent() string {
+	if agent := os.Getenv("ROBOTS_USER_AGENT"); agent != "" {
+		return agent
+	}
+	return DefaultRobotsAgent
+}
+
+type robotsEntry struct {
+	data    *robotstxt.RobotsData
+	expires time.Time
+}
+
+// RobotsCache fetches robots.txt once per host and enforces its rules and
+// Crawl-delay.
+type RobotsCache struct {
+	Client *http.Client
+
+	mu        sync.Mutex
+	entries   map[string]robotsEntry
+	nextVisit map[string]time.Time
+}
+
+func NewRobotsCache() *RobotsCache {
+	return &RobotsCache{
+		Client:    &http.Client{Timeout: 10 * time.Second},
+		entries:   make(map[string]robotsEntry),
+		nextVisit: make(map[string]time.Time),
+	}
+}
+
+// Admit returns ErrBlockedByRobots if agent may not crawl targetURL, and
+// otherwise waits until the host's Crawl-delay has passed since the previous
+// page admitted for that host.
+func (rc *RobotsCache) Admit(ctx context.Context, targetURL, agent string) error {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil
+	}
+
+	group := rc.robots(ctx, u).FindGroup(agent)
+	if !group.Test(robotsPath(u)) {
+		return ErrBlockedByRobots
+	}
+
+	delay := group.CrawlDelay
+	if delay <= 0 {
+		return nil
+	}
+	if delay > maxCrawlDelay {
+		delay = maxCrawlDelay
+	}
+
+	key := strings.ToLower(u.Host)
+	rc.mu.Lock()
+	now := time.Now()
+	at := rc.nextVisit[key]
+	if at.Before(now) {
+		at = now
+	}
+	rc.nextVisit[key] = at.Add(delay)
+	rc.mu.Unlock()
+
+	select {
+	case <-time.After(time.Until(at)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// robots returns the parsed robots.txt of u's host, fetching it if needed.
+// A robots.txt that cannot be fetched, or that answers with a server error,
+// allows everything and is fetched again after robotsErrorTTL.
+func (rc *RobotsCache) robots(ctx context.Context, u *url.URL) *robotstxt.RobotsData {
+	key := u.Scheme + "://" + strings.ToLower(u.Host)
+
+	rc.mu.Lock()
+	entry, ok := rc.entries[key]
+	rc.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.data
+	}
+
+	data, err := rc.fetch(ctx, key+"/robots.txt")
+	ttl := robotsTTL
+	if err != nil {
+		data, ttl = &robotstxt.RobotsData{}, robotsErrorTTL
+		if ctx.Err() != nil {
+			return data
+		}
+	}
+
+	rc.mu.Lock()
+	rc.entries[key] = robotsEntry{data: data, expires: time.Now().Add(ttl)}
+	rc.mu.Unlock()
+	return data
+}
+
+func (rc *RobotsCache) fetch(ctx context.Context, robotsURL string) (*robotstxt.RobotsData, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent())
+	resp, err := rc.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// FromResponse would treat a 5xx as "disallow all", which would block
+	// the host for robotsTTL after a single transient error.
+	if resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("robots.txt: HTTP %d", resp.StatusCode)
+	}
+	resp.Body = http.MaxBytesReader(nil, resp.Body, maxRobotsTxtLen)
+	// FromResponse treats 4xx as "allow all".
+	return robotstxt.FromResponse(resp)
+}
+
+func robotsPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return path
+}
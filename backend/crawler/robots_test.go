@@ -0,0 +1,72 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRobotsCacheAdmit(t *testing.T) {
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fetches++
+			fmt.Fprint(w, "User-agent: ScrawlingDashboard\nDisallow: /private\nCrawl-delay: 1\n")
+		}
+	}))
+	defer srv.Close()
+
+	rc := NewRobotsCache()
+	ctx := context.Background()
+	if err := rc.Admit(ctx, srv.URL+"/private/page", DefaultRobotsAgent); !errors.Is(err, ErrBlockedByRobots) {
+		t.Errorf("disallowed page: got %v, want ErrBlockedByRobots", err)
+	}
+	if err := rc.Admit(ctx, srv.URL+"/private", "OtherBot"); err != nil {
+		t.Errorf("other agent: %v", err)
+	}
+
+	start := time.Now()
+	for _, path := range []string{"/a", "/b"} {
+		if err := rc.Admit(ctx, srv.URL+path, DefaultRobotsAgent); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("two pages admitted %v apart, want the 1s Crawl-delay", elapsed)
+	}
+	if fetches != 1 {
+		t.Errorf("robots.txt fetched %d times, want 1", fetches)
+	}
+}
+
+func TestRobotsCacheMissing(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	if err := NewRobotsCache().Admit(context.Background(), srv.URL+"/private", DefaultRobotsAgent); err != nil {
+		t.Errorf("a host without robots.txt blocks crawling: %v", err)
+	}
+}
+
+func TestRobotsCacheServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	rc := NewRobotsCache()
+	if err := rc.Admit(context.Background(), srv.URL+"/page", DefaultRobotsAgent); err != nil {
+		t.Fatalf("a robots.txt answering 503 blocks the host: %v", err)
+	}
+	u, _ := url.Parse(srv.URL)
+	entry := rc.entries[u.Scheme+"://"+strings.ToLower(u.Host)]
+	if ttl := time.Until(entry.expires); ttl > robotsErrorTTL {
+		t.Errorf("robots.txt answering 503 cached for %v, want at most %v", ttl, robotsErrorTTL)
+	}
+}
@@ -0,0 +1,247 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"scrawling_dashboard/backend/models"
+)
+
+const (
+	DefaultMaxDepth = 2
+	DefaultMaxPages = 50
+	MaxSitePages    = 500
+
+	sitePageTimeout = 2 * time.Minute
+)
+
+// sitePatterns holds the compiled include/exclude path filters.
+type sitePatterns struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// allows reports whether a path passes the include and exclude filters.
+func (p sitePatterns) allows(path string) bool {
+	for _, re := range p.exclude {
+		if re.MatchString(path) {
+			return false
+		}
+	}
+	if len(p.include) == 0 {
+		return true
+	}
+	for _, re := range p.include {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func compilePatterns(exprs []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path pattern %q: %w", expr, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// NormalizeSiteOptions fills in defaults and validates the path patterns.
+func NormalizeSiteOptions(opts models.SiteOptions) (models.SiteOptions, error) {
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultMaxDepth
+	}
+	if opts.MaxPages <= 0 {
+		opts.MaxPages = DefaultMaxPages
+	}
+	if opts.MaxPages > MaxSitePages {
+		opts.MaxPages = MaxSitePages
+	}
+	if _, err := compilePatterns(opts.Include); err != nil {
+		return opts, err
+	}
+	if _, err := compilePatterns(opts.Exclude); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// CrawlSite crawls startURL and then follows its internal links
+// breadth-first, calling onPage for every page analyzed. Pages that fail are
+// reported with status "error" (or "blocked" when robots.txt disallows them)
+// and do not stop the crawl.
+func (c *Crawler) CrawlSite(ctx context.Context, startURL string, opts models.SiteOptions,
+	onPage func(*models.CrawlResult) error) error {
+	_, err := c.ResumeSite(ctx, startURL, opts, nil, onPage)
+	return err
+}
+
+// ResumeSite is CrawlSite continuing from a frontier returned by an earlier,
+// interrupted call, or from startURL if frontier is nil. When ctx is done or
+// onPage fails it returns the frontier to resume from along with the error;
+// the page that was being crawled or handed to onPage is crawled again on
+// resume.
+func (c *Crawler) ResumeSite(ctx context.Context, startURL string, opts models.SiteOptions,
+	frontier *models.SiteFrontier, onPage func(*models.CrawlResult) error) (*models.SiteFrontier, error) {
+	opts, err := NormalizeSiteOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	include, _ := compilePatterns(opts.Include)
+	exclude, _ := compilePatterns(opts.Exclude)
+	patterns := sitePatterns{include: include, exclude: exclude}
+
+	start, err := url.Parse(startURL)
+	if err != nil || start.Hostname() == "" {
+		return nil, fmt.Errorf("invalid base URL")
+	}
+
+	state := frontier
+	if state == nil {
+		state = &models.SiteFrontier{
+			Pending: []models.FrontierEntry{{URL: startURL, Depth: 0}},
+			Visited: []string{normalizePageURL(start)},
+		}
+	} else {
+		state = state.Clone()
+	}
+	visited := make(map[string]bool, len(state.Visited))
+	for _, key := range state.Visited {
+		visited[key] = true
+	}
+
+	for len(state.Pending) > 0 && state.Crawled < opts.MaxPages {
+		if err := ctx.Err(); err != nil {
+			return state, fmt.Errorf("crawl canceled: %w", err)
+		}
+
+		entry := state.Pending[0]
+
+		pageCtx, cancel := context.WithTimeout(ctx, sitePageTimeout)
+		result, doc, err := c.analyzePage(pageCtx, entry.URL)
+		cancel()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return state, fmt.Errorf("crawl canceled: %w", ctx.Err())
+			}
+			status := "error"
+			if errors.Is(err, ErrBlockedByRobots) {
+				status = "blocked"
+			}
+			result = &models.CrawlResult{
+				URL:          entry.URL,
+				Status:       status,
+				ErrorMessage: err.Error(),
+			}
+		} else {
+			result.Status = "done"
+		}
+		result.Depth = entry.Depth
+		if err := onPage(result); err != nil {
+			return state, err
+		}
+		state.Pending = state.Pending[1:]
+		state.Crawled++
+
+		if doc == nil || entry.Depth >= opts.MaxDepth {
+			continue
+		}
+		// Relative links resolve against the page the redirects ended on.
+		pageURL := entry.URL
+		if result.Redirects != nil && result.Redirects.FinalURL != "" {
+			pageURL = result.Redirects.FinalURL
+		}
+		for _, link := range internalPageLinks(doc, pageURL, start.Hostname()) {
+			key := normalizePageURL(link)
+			if visited[key] || !patterns.allows(link.Path) {
+				continue
+			}
+			visited[key] = true
+			state.Visited = append(state.Visited, key)
+			state.Pending = append(state.Pending, models.FrontierEntry{URL: link.String(), Depth: entry.Depth + 1})
+		}
+	}
+
+	return state, nil
+}
+
+// internalPageLinks returns the http(s) links of doc that stay on host, in
+// document order and without fragments.
+func internalPageLinks(doc *goquery.Document, pageURL, host string) []*url.URL {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	var links []*url.URL
+	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		link, err := base.Parse(strings.TrimSpace(href))
+		if err != nil {
+			return
+		}
+		if link.Scheme != "http" && link.Scheme != "https" {
+			return
+		}
+		if !strings.EqualFold(link.Hostname(), host) {
+			return
+		}
+		link.Fragment = ""
+		links = append(links, link)
+	})
+	return links
+}
+
+// normalizePageURL returns the key used to detect already visited pages.
+func normalizePageURL(u *url.URL) string {
+	n := *u
+	n.Fragment = ""
+	n.Host = strings.ToLower(n.Host)
+	if n.Path == "" {
+		n.Path = "/"
+	}
+	return n.String()
+}
+
+// NormalizeURL checks a submitted page URL and returns its canonical form:
+// lower-case scheme and host, no default port, no fragment and "/" for an
+// empty path. URLs without a scheme are taken as https.
+func NormalizeURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("empty URL")
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL")
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("missing host")
+	}
+	if port := u.Port(); port == "80" && u.Scheme == "http" || port == "443" && u.Scheme == "https" {
+		u.Host = u.Hostname()
+		if strings.Contains(u.Host, ":") {
+			u.Host = "[" + u.Host + "]"
+		}
+	}
+	return normalizePageURL(u), nil
+}
@@ -0,0 +1,237 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"scrawling_dashboard/backend/models"
+)
+
+func TestNormalizeSiteOptions(t *testing.T) {
+	opts, err := NormalizeSiteOptions(models.SiteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.MaxDepth != DefaultMaxDepth || opts.MaxPages != DefaultMaxPages {
+		t.Errorf("defaults = %+v, want depth %d and %d pages", opts, DefaultMaxDepth, DefaultMaxPages)
+	}
+
+	opts, err = NormalizeSiteOptions(models.SiteOptions{MaxDepth: 5, MaxPages: MaxSitePages + 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.MaxDepth != 5 || opts.MaxPages != MaxSitePages {
+		t.Errorf("limits = %+v, want depth 5 and pages capped at %d", opts, MaxSitePages)
+	}
+
+	if _, err := NormalizeSiteOptions(models.SiteOptions{Include: []string{"("}}); err == nil {
+		t.Error("an invalid include pattern is accepted")
+	}
+	if _, err := NormalizeSiteOptions(models.SiteOptions{Exclude: []string{"["}}); err == nil {
+		t.Error("an invalid exclude pattern is accepted")
+	}
+}
+
+func TestSitePatternsAllows(t *testing.T) {
+	include, _ := compilePatterns([]string{"^/blog"})
+	exclude, _ := compilePatterns([]string{"/tag/"})
+	patterns := sitePatterns{include: include, exclude: exclude}
+
+	for path, want := range map[string]bool{
+		"/blog/post":    true,
+		"/blog/tag/go":  false,
+		"/about":        false,
+		"/blog":         true,
+		"/tag/blog/old": false,
+	} {
+		if got := patterns.allows(path); got != want {
+			t.Errorf("allows(%q) = %v, want %v", path, got, want)
+		}
+	}
+	if !(sitePatterns{}).allows("/anything") {
+		t.Error("no patterns should allow every path")
+	}
+}
+
+func TestInternalPageLinks(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body>
+<a href="/a#top">a</a>
+<a href="b">b</a>
+<a href="https://EXAMPLE.com/c">c</a>
+<a href="https://other.example/d">external</a>
+<a href="mailto:me@example.com">mail</a>
+</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, link := range internalPageLinks(doc, "https://example.com/dir/page", "example.com") {
+		got = append(got, link.String())
+	}
+	want := []string{"https://example.com/a", "https://example.com/dir/b", "https://EXAMPLE.com/c"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("internalPageLinks = %v, want %v", got, want)
+	}
+}
+
+func TestNormalizePageURL(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://Example.com":          "https://example.com/",
+		"https://example.com/a#frag":   "https://example.com/a",
+		"https://example.com/a?b=1#cd": "https://example.com/a?b=1",
+	} {
+		u, _ := url.Parse(raw)
+		if got := normalizePageURL(u); got != want {
+			t.Errorf("normalizePageURL(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestCrawlSite(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><a href="/a">a</a><a href="/b#x">b</a><a href="/skip/c">c</a><a href="/missing">m</a></html>`)
+		case "/a":
+			fmt.Fprint(w, `<html><a href="/a/deep">deep</a></html>`)
+		case "/b", "/a/deep":
+			fmt.Fprint(w, `<html><a href="/">home</a></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(&StaticFetcher{Client: srv.Client()})
+	depths := make(map[string]int)
+	statuses := make(map[string]string)
+	err := c.CrawlSite(context.Background(), srv.URL+"/", models.SiteOptions{MaxDepth: 1, Exclude: []string{"^/skip"}},
+		func(page *models.CrawlResult) error {
+			path := strings.TrimPrefix(page.URL, srv.URL)
+			depths[path], statuses[path] = page.Depth, page.Status
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// /a/deep is beyond MaxDepth and /skip/c is excluded.
+	want := map[string]int{"/": 0, "/a": 1, "/b": 1, "/missing": 1}
+	if fmt.Sprint(depths) != fmt.Sprint(want) {
+		t.Errorf("crawled %v, want %v", depths, want)
+	}
+	if statuses["/missing"] != "error" || statuses["/a"] != "done" {
+		t.Errorf("statuses = %v, want the missing page reported as an error", statuses)
+	}
+
+	var pages int
+	err = c.CrawlSite(context.Background(), srv.URL+"/", models.SiteOptions{MaxDepth: 2, MaxPages: 2},
+		func(*models.CrawlResult) error {
+			pages++
+			return nil
+		})
+	if err != nil || pages != 2 {
+		t.Errorf("crawled %d pages (%v), want MaxPages = 2", pages, err)
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{raw: "example.com", want: "https://example.com/"},
+		{raw: " HTTPS://Example.COM:443/a?b=1#top ", want: "https://example.com/a?b=1"},
+		{raw: "http://example.com:8080", want: "http://example.com:8080/"},
+		{raw: "", wantErr: true},
+		{raw: "ftp://example.com/", wantErr: true},
+		{raw: "https:///path", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeURL(tt.raw)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NormalizeURL(%q) = %q, %v; want %q, error %v", tt.raw, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestResumeSite(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><a href="/a">a</a><a href="/b">b</a></html>`)
+	}))
+	defer srv.Close()
+
+	c := New(&StaticFetcher{Client: srv.Client()})
+	start := srv.URL + "/"
+	ctx, cancel := context.WithCancel(context.Background())
+	var crawled []string
+	frontier, err := c.ResumeSite(ctx, start, models.SiteOptions{}, nil, func(page *models.CrawlResult) error {
+		crawled = append(crawled, page.URL)
+		cancel()
+		return nil
+	})
+	if err == nil {
+		t.Fatal("an interrupted crawl returned no error")
+	}
+	if frontier == nil || frontier.Crawled != 1 || len(frontier.Pending) != 2 {
+		t.Fatalf("frontier = %+v, want the start page crawled and both links pending", frontier)
+	}
+
+	_, err = c.ResumeSite(context.Background(), start, models.SiteOptions{}, frontier, func(page *models.CrawlResult) error {
+		crawled = append(crawled, page.URL)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{start, srv.URL + "/a", srv.URL + "/b"}
+	if strings.Join(crawled, " ") != strings.Join(want, " ") {
+		t.Errorf("crawled %v, want %v with no page crawled twice", crawled, want)
+	}
+}
+
+func TestResumeSiteKeepsUnstoredPage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><a href="/a">a</a><a href="/b">b</a></html>`)
+	}))
+	defer srv.Close()
+
+	c := New(&StaticFetcher{Client: &http.Client{}})
+	start := srv.URL + "/"
+	var stored []string
+	failed := false
+	frontier, err := c.ResumeSite(context.Background(), start, models.SiteOptions{}, nil, func(page *models.CrawlResult) error {
+		if page.URL == start && !failed {
+			failed = true
+			return fmt.Errorf("%w: database is down", ErrNotStored)
+		}
+		stored = append(stored, page.URL)
+		return nil
+	})
+	if !errors.Is(err, ErrNotStored) {
+		t.Fatalf("got %v, want ErrNotStored", err)
+	}
+	if frontier == nil || frontier.Crawled != 0 || len(frontier.Pending) != 1 {
+		t.Fatalf("frontier = %+v, want the start page still pending", frontier)
+	}
+
+	if _, err := c.ResumeSite(context.Background(), start, models.SiteOptions{}, frontier, func(page *models.CrawlResult) error {
+		stored = append(stored, page.URL)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 3 || stored[0] != start {
+		t.Errorf("stored %v, want the start page first and both links", stored)
+	}
+}
@@ -0,0 +1,172 @@
+package crawler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxSitemapURLs caps the URLs collected from one sitemap submission.
+	MaxSitemapURLs = 50000
+
+	maxSitemapFiles = 200
+	maxSitemapSize  = 50 << 20
+)
+
+// sitemapDoc matches both <urlset> and <sitemapindex> documents.
+type sitemapDoc struct {
+	URLs     []sitemapItem `xml:"url"`
+	Sitemaps []sitemapItem `xml:"sitemap"`
+}
+
+type sitemapItem struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// SitemapReader collects page URLs from sitemaps.
+type SitemapReader struct {
+	Client *http.Client
+}
+
+func NewSitemapReader() *SitemapReader {
+	return &SitemapReader{Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Collect returns the <loc> entries of sitemapURL, following sitemap index
+// files and reading gzipped sitemaps. Entries whose <lastmod> is before since
+// are skipped; entries without <lastmod> are always kept. A zero since keeps
+// everything.
+func (sr *SitemapReader) Collect(ctx context.Context, sitemapURL string, since time.Time) ([]string, error) {
+	var (
+		locs    []string
+		seen    = map[string]bool{}
+		pending = []string{sitemapURL}
+		visited = map[string]bool{}
+	)
+
+	for len(pending) > 0 && len(locs) < MaxSitemapURLs {
+		if len(visited) >= maxSitemapFiles {
+			break
+		}
+		current := pending[0]
+		pending = pending[1:]
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+
+		doc, err := sr.fetch(ctx, current)
+		if err != nil {
+			// Only the submitted sitemap is required to load.
+			if current == sitemapURL {
+				return nil, err
+			}
+			continue
+		}
+
+		for _, child := range doc.Sitemaps {
+			if loc := strings.TrimSpace(child.Loc); loc != "" && modifiedSince(child.LastMod, since) {
+				pending = append(pending, loc)
+			}
+		}
+		for _, entry := range doc.URLs {
+			loc := strings.TrimSpace(entry.Loc)
+			if loc == "" || seen[loc] || !modifiedSince(entry.LastMod, since) {
+				continue
+			}
+			seen[loc] = true
+			locs = append(locs, loc)
+			if len(locs) >= MaxSitemapURLs {
+				break
+			}
+		}
+	}
+
+	return locs, nil
+}
+
+// fetch downloads and decodes one sitemap file, gunzipping it if needed.
+func (sr *SitemapReader) fetch(ctx context.Context, sitemapURL string) (*sitemapDoc, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sitemapURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sitemap URL: %w", err)
+	}
+	req.Header.Set("User-Agent", UserAgent())
+	resp, err := sr.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sitemap request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("sitemap returned HTTP %d", resp.StatusCode)
+	}
+
+	// .xml.gz files are usually served as-is, so sniff the gzip header
+	// instead of trusting Content-Encoding.
+	body := bufio.NewReader(io.LimitReader(resp.Body, maxSitemapSize))
+	var reader io.Reader = body
+	if magic, err := body.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzipped sitemap: %w", err)
+		}
+		defer gz.Close()
+		reader = io.LimitReader(gz, maxSitemapSize)
+	}
+
+	var doc sitemapDoc
+	if err := xml.NewDecoder(reader).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid sitemap XML: %w", err)
+	}
+	return &doc, nil
+}
+
+// modifiedSince reports whether a <lastmod> value is at or after since.
+// Missing or unparsable values count as modified.
+func modifiedSince(lastMod string, since time.Time) bool {
+	if since.IsZero() {
+		return true
+	}
+	t, ok := ParseW3CDate(lastMod)
+	return !ok || !t.Before(since)
+}
+
+// ParseW3CDate parses the W3C datetime formats used by sitemaps, from a bare
+// year up to a full timestamp.
+func ParseW3CDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02",
+		"2006-01",
+		"2006",
+	} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Sitemaps returns the sitemaps listed in the robots.txt of siteURL's host,
+// or the conventional /sitemap.xml if it lists none.
+func (rc *RobotsCache) Sitemaps(ctx context.Context, siteURL string) ([]string, error) {
+	u, err := url.Parse(siteURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid site URL")
+	}
+	if sitemaps := rc.robots(ctx, u).Sitemaps; len(sitemaps) > 0 {
+		return sitemaps, nil
+	}
+	return []string{u.Scheme + "://" + u.Host + "/sitemap.xml"}, nil
+}
@@ -0,0 +1,72 @@
+package crawler
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSitemapDiscoveryAndCollect(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "Sitemap: %s/index.xml\n", srv.URL)
+		case "/index.xml":
+			fmt.Fprintf(w, `<?xml version="1.0"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<sitemap><loc>%[1]s/pages.xml.gz</loc></sitemap>
+<sitemap><loc>%[1]s/old.xml</loc><lastmod>2020-01-01</lastmod></sitemap>
+</sitemapindex>`, srv.URL)
+		case "/pages.xml.gz":
+			gz := gzip.NewWriter(w)
+			fmt.Fprintf(gz, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>%[1]s/p1</loc><lastmod>2025-05-01T10:00:00+00:00</lastmod></url>
+<url><loc>%[1]s/p2</loc><lastmod>2019-01-01</lastmod></url>
+<url><loc>%[1]s/p3</loc></url>
+</urlset>`, srv.URL)
+			gz.Close()
+		case "/old.xml":
+			t.Error("sitemap older than since was fetched")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	sitemaps, err := NewRobotsCache().Sitemaps(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{srv.URL + "/index.xml"}; !reflect.DeepEqual(sitemaps, want) {
+		t.Fatalf("Sitemaps = %v, want %v", sitemaps, want)
+	}
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	locs, err := NewSitemapReader().Collect(context.Background(), sitemaps[0], since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// p2 was last modified before since; p3 has no lastmod and is kept.
+	if want := []string{srv.URL + "/p1", srv.URL + "/p3"}; !reflect.DeepEqual(locs, want) {
+		t.Errorf("Collect = %v, want %v", locs, want)
+	}
+}
+
+func TestSitemapsFallback(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	sitemaps, err := NewRobotsCache().Sitemaps(context.Background(), srv.URL+"/some/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{srv.URL + "/sitemap.xml"}; !reflect.DeepEqual(sitemaps, want) {
+		t.Errorf("Sitemaps = %v, want %v", sitemaps, want)
+	}
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+// jobInsertBatch is the number of jobs inserted per statement.
+const jobInsertBatch = 500
+
+// CreateBatch stores a new batch with a queued job for each of urls, all or
+// none of them, and returns the ID of the batch and those of its jobs in the
+// order of urls.
+func (s *SQLStore) CreateBatch(batch models.Batch, urls []string, opts models.CrawlOptions) (int64, []int64, error) {
+	optionsJSON, _ := json.Marshal(opts)
+	now := time.Now()
+	project := opts.Project
+	if project == "" {
+		project = "default"
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO crawl_batches (source, total, accepted, rejected, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		batch.Source, batch.Total, batch.Accepted, batch.Rejected, now)
+	if err != nil {
+		return 0, nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for start := 0; start < len(urls); start += jobInsertBatch {
+		chunk := urls[start:min(start+jobInsertBatch, len(urls))]
+		rows := make([]string, len(chunk))
+		args := make([]interface{}, 0, len(chunk)*8)
+		for i, url := range chunk {
+			rows[i] = `(?, ?, ?, ?, ?, ?, ?, ?)`
+			args = append(args, id, url, optionsJSON, models.JobQueued,
+				models.PriorityRank(opts.Priority), project, now, now)
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO crawl_jobs (batch_id, url, options, state, priority, project, created_at, updated_at)
+			VALUES `+strings.Join(rows, `, `), args...); err != nil {
+			return 0, nil, err
+		}
+	}
+	// The batch is new, so its jobs are the ones just inserted, and their
+	// IDs grow in the order they were inserted.
+	if _, err := tx.Exec(`
+		INSERT INTO crawl_job_events (job_id, from_state, to_state, message, created_at)
+		SELECT id, '', ?, '', ? FROM crawl_jobs WHERE batch_id = ?`,
+		models.JobQueued, now, id); err != nil {
+		return 0, nil, err
+	}
+	jobIDs, err := queryIDs(tx, `SELECT id FROM crawl_jobs WHERE batch_id = ? ORDER BY id`, id)
+	if err != nil {
+		return 0, nil, err
+	}
+	return id, jobIDs, tx.Commit()
+}
+
+// SetBatchPaused pauses or resumes a batch. It returns sql.ErrNoRows if
+// there is none.
+func (s *SQLStore) SetBatchPaused(id int64, paused bool) error {
+	if _, err := s.GetBatch(id); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`UPDATE crawl_batches SET paused = ? WHERE id = ?`, paused, id)
+	return err
+}
+
+// PausedBatches returns the IDs of the paused batches.
+func (s *SQLStore) PausedBatches() ([]int64, error) {
+	rows, err := s.db.Query(`SELECT id FROM crawl_batches WHERE paused = TRUE`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetBatch returns a batch by ID. It returns sql.ErrNoRows if there is none.
+func (s *SQLStore) GetBatch(id int64) (models.Batch, error) {
+	var (
+		batch     models.Batch
+		createdAt string
+	)
+	err := s.db.QueryRow(`
+		SELECT id, source, total, accepted, rejected, paused, created_at
+		FROM crawl_batches WHERE id = ?`, id).
+		Scan(&batch.ID, &batch.Source, &batch.Total, &batch.Accepted, &batch.Rejected, &batch.Paused, &createdAt)
+	if err != nil {
+		return batch, err
+	}
+	batch.CreatedAt, _ = time.Parse(timeLayout, createdAt)
+	return batch, nil
+}
+
+// BatchJobs returns the jobs of a batch in submission order.
+func (s *SQLStore) BatchJobs(id int64) ([]models.Job, error) {
+	jobs, err := s.queryJobs(`WHERE batch_id = ? ORDER BY id`, id)
+	if jobs == nil {
+		jobs = []models.Job{}
+	}
+	return jobs, err
+}
@@ -0,0 +1,562 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+const (
+	jobColumns = `id, batch_id, url, options, state, attempts, result_id, error_message,
+		created_at, updated_at, started_at, finished_at, run_after, frontier,
+		worker_id, lease_until, phase`
+	timeLayout = "2006-01-02 15:04:05"
+)
+
+// ErrLeaseLost is returned to a worker updating a job it no longer holds,
+// because its lease expired and the job was reclaimed.
+var ErrLeaseLost = errors.New("job lease lost")
+
+// CreateJob stores a new queued job and returns its ID. batchID is 0 for
+// jobs not submitted as part of a batch.
+func (s *SQLStore) CreateJob(url string, opts models.CrawlOptions, batchID int64) (int64, error) {
+	optionsJSON, _ := json.Marshal(opts)
+	now := time.Now()
+	project := opts.Project
+	if project == "" {
+		project = "default"
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var batch sql.NullInt64
+	if batchID > 0 {
+		batch = sql.NullInt64{Int64: batchID, Valid: true}
+	}
+	res, err := tx.Exec(`
+		INSERT INTO crawl_jobs (batch_id, url, options, state, priority, project, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		batch, url, optionsJSON, models.JobQueued, models.PriorityRank(opts.Priority), project, now, now)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := insertJobEvent(tx, id, "", models.JobQueued, "", now); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// TransitionJob moves a job that is not running to a new state and records
+// the transition. Moving to a final state stores the message as the job's
+// error, if any.
+func (s *SQLStore) TransitionJob(id int64, to, message string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var from string
+	if err := tx.QueryRow(`SELECT state FROM crawl_jobs WHERE id = ?`+s.dialect.forUpdate, id).Scan(&from); err != nil {
+		return fmt.Errorf("job %d: %w", id, err)
+	}
+	if err := setJobState(tx, id, from, to, message); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setJobState moves a job locked by tx to a new state, releases its lease
+// and records the transition.
+func setJobState(tx *sql.Tx, id int64, from, to, message string) error {
+	now := time.Now()
+	var err error
+	switch to {
+	case models.JobDone, models.JobError, models.JobBlocked, models.JobCanceled:
+		_, err = tx.Exec(`
+			UPDATE crawl_jobs SET state = ?, error_message = ?, finished_at = ?, frontier = NULL,
+				lease_until = NULL, interrupt_to = NULL, phase = NULL, updated_at = ?
+			WHERE id = ?`, to, message, now, now, id)
+	default:
+		_, err = tx.Exec(`
+			UPDATE crawl_jobs SET state = ?, lease_until = NULL, interrupt_to = NULL, phase = NULL,
+				updated_at = ?
+			WHERE id = ?`, to, now, id)
+	}
+	if err != nil {
+		return err
+	}
+	return insertJobEvent(tx, id, from, to, message, now)
+}
+
+// CancelJob cancels a queued or paused job, or asks the worker running it to
+// stop; the worker then records it as canceled. It reports whether the job
+// was unfinished.
+func (s *SQLStore) CancelJob(id int64) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var state string
+	if err := tx.QueryRow(`SELECT state FROM crawl_jobs WHERE id = ?`+s.dialect.forUpdate, id).Scan(&state); err != nil {
+		return false, fmt.Errorf("job %d: %w", id, err)
+	}
+	switch state {
+	case models.JobQueued, models.JobPaused:
+		err = setJobState(tx, id, state, models.JobCanceled, "canceled while "+state)
+	case models.JobRunning:
+		_, err = tx.Exec(`UPDATE crawl_jobs SET interrupt_to = ? WHERE id = ?`, models.JobCanceled, id)
+	default:
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// InterruptJobs asks the workers to stop the running jobs, of one batch if
+// batchID is not 0, and to put them back in state to: queued or paused.
+// Jobs already being stopped are left alone. It returns the IDs of the
+// interrupted jobs.
+func (s *SQLStore) InterruptJobs(to string, batchID int64) ([]int64, error) {
+	where := `state = ? AND interrupt_to IS NULL`
+	args := []interface{}{models.JobRunning}
+	if batchID != 0 {
+		where += ` AND batch_id = ?`
+		args = append(args, batchID)
+	}
+	return s.updateJobs(where, args, func(tx *sql.Tx, id int64) error {
+		_, err := tx.Exec(`UPDATE crawl_jobs SET interrupt_to = ? WHERE id = ?`, to, id)
+		return err
+	})
+}
+
+// MoveBatchJobs moves the jobs of a batch that are in state from to state
+// to, e.g. to hold the queued jobs of a paused batch. It returns their IDs.
+func (s *SQLStore) MoveBatchJobs(batchID int64, from, to, message string) ([]int64, error) {
+	return s.updateJobs(`state = ? AND batch_id = ?`, []interface{}{from, batchID}, func(tx *sql.Tx, id int64) error {
+		return setJobState(tx, id, from, to, message)
+	})
+}
+
+// updateJobs locks the jobs matching where and calls update for each of
+// them in one transaction. It returns their IDs.
+func (s *SQLStore) updateJobs(where string, args []interface{}, update func(*sql.Tx, int64) error) ([]int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ids, err := queryIDs(tx, `SELECT id FROM crawl_jobs WHERE `+where+` ORDER BY id`+s.dialect.forUpdate, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if err := update(tx, id); err != nil {
+			return nil, err
+		}
+	}
+	return ids, tx.Commit()
+}
+
+// FinishJob records the final state of a job run by workerID. A job that was
+// canceled while running ends in canceled instead. It returns the state the
+// job ended in, or ErrLeaseLost.
+func (s *SQLStore) FinishJob(id int64, workerID, state, message string) (string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	interrupt, err := s.lockRunningJob(tx, id, workerID)
+	if err != nil {
+		return "", err
+	}
+	if interrupt == models.JobCanceled {
+		state = models.JobCanceled
+	}
+	if err := setJobState(tx, id, models.JobRunning, state, message); err != nil {
+		return "", err
+	}
+	return state, tx.Commit()
+}
+
+// RetryJob queues a failed job run by workerID again for an attempt at
+// runAfter; a job of a paused batch waits in paused. message, the error of
+// the failed attempt, is kept in the job's history. A site crawl's frontier
+// is kept so the next attempt resumes where this one failed. A job canceled
+// in the meantime ends in canceled instead. It returns the job's new state,
+// or ErrLeaseLost.
+func (s *SQLStore) RetryJob(id int64, workerID string, runAfter time.Time, message string, frontier *models.SiteFrontier) (string, error) {
+	var frontierJSON []byte
+	if frontier != nil {
+		frontierJSON, _ = json.Marshal(frontier)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	interrupt, err := s.lockRunningJob(tx, id, workerID)
+	if err != nil {
+		return "", err
+	}
+	to := models.JobCanceled
+	if interrupt != models.JobCanceled {
+		if to, err = heldState(tx, id); err != nil {
+			return "", err
+		}
+	}
+	if err := setJobState(tx, id, models.JobRunning, to, message); err != nil {
+		return "", err
+	}
+	if to != models.JobCanceled {
+		if _, err := tx.Exec(`UPDATE crawl_jobs SET error_message = ?, run_after = ?, frontier = ? WHERE id = ?`,
+			message, runAfter, frontierJSON, id); err != nil {
+			return "", err
+		}
+	}
+	return to, tx.Commit()
+}
+
+// HoldJob puts a job run by workerID that was interrupted without failing
+// back in queued, or in paused if its batch is paused. The interrupted run
+// does not count as an attempt. A site crawl's frontier is kept so it can
+// resume where it stopped. A job canceled in the meantime ends in canceled
+// instead. It returns the job's new state, or ErrLeaseLost.
+func (s *SQLStore) HoldJob(id int64, workerID, message string, frontier *models.SiteFrontier) (string, error) {
+	var frontierJSON []byte
+	if frontier != nil {
+		frontierJSON, _ = json.Marshal(frontier)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	interrupt, err := s.lockRunningJob(tx, id, workerID)
+	if err != nil {
+		return "", err
+	}
+	if interrupt == models.JobCanceled {
+		if err := setJobState(tx, id, models.JobRunning, models.JobCanceled, "canceled by user"); err != nil {
+			return "", err
+		}
+		return models.JobCanceled, tx.Commit()
+	}
+	to, err := heldState(tx, id)
+	if err != nil {
+		return "", err
+	}
+	if err := setJobState(tx, id, models.JobRunning, to, message); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(`
+		UPDATE crawl_jobs SET attempts = `+s.dialect.greatest+`(attempts - 1, 0), frontier = ? WHERE id = ?`,
+		frontierJSON, id); err != nil {
+		return "", err
+	}
+	return to, tx.Commit()
+}
+
+// lockRunningJob locks a job that workerID is running and returns the state
+// it was asked to stop for, if any. It returns ErrLeaseLost if the job is
+// no longer running on workerID.
+func (s *SQLStore) lockRunningJob(tx *sql.Tx, id int64, workerID string) (string, error) {
+	var (
+		state            string
+		owner, interrupt sql.NullString
+	)
+	err := tx.QueryRow(`SELECT state, worker_id, interrupt_to FROM crawl_jobs WHERE id = ?`+s.dialect.forUpdate, id).
+		Scan(&state, &owner, &interrupt)
+	if err != nil {
+		return "", fmt.Errorf("job %d: %w", id, err)
+	}
+	if state != models.JobRunning || owner.String != workerID {
+		return "", ErrLeaseLost
+	}
+	return interrupt.String, nil
+}
+
+// heldState is the state an unfinished job waits in: paused if its batch is
+// paused, queued otherwise.
+func heldState(tx *sql.Tx, id int64) (string, error) {
+	var paused sql.NullBool
+	err := tx.QueryRow(`
+		SELECT b.paused FROM crawl_jobs j LEFT JOIN crawl_batches b ON b.id = j.batch_id
+		WHERE j.id = ?`, id).Scan(&paused)
+	if err != nil {
+		return "", err
+	}
+	if paused.Bool {
+		return models.JobPaused, nil
+	}
+	return models.JobQueued, nil
+}
+
+// SetJobPhase records the phase of a job run by workerID.
+func (s *SQLStore) SetJobPhase(id int64, workerID string, phase models.JobPhase) error {
+	phaseJSON, _ := json.Marshal(phase)
+	_, err := s.db.Exec(`UPDATE crawl_jobs SET phase = ? WHERE id = ? AND worker_id = ? AND state = ?`,
+		phaseJSON, id, workerID, models.JobRunning)
+	return err
+}
+
+// SetJobResult links a job to the urls row holding its result
+func (s *SQLStore) SetJobResult(id, resultID int64) error {
+	_, err := s.db.Exec(`UPDATE crawl_jobs SET result_id = ?, updated_at = ? WHERE id = ?`,
+		resultID, time.Now(), id)
+	return err
+}
+
+// GetJob returns a job by ID. It returns sql.ErrNoRows if there is none.
+func (s *SQLStore) GetJob(id int64) (models.Job, error) {
+	return scanJob(s.db.QueryRow(`SELECT `+jobColumns+` FROM crawl_jobs WHERE id = ?`, id))
+}
+
+// UnfinishedJobs returns the IDs of the queued, running and paused jobs
+// matching filter.
+func (s *SQLStore) UnfinishedJobs(filter JobFilter) ([]int64, error) {
+	where := `state IN (?, ?, ?)`
+	args := []interface{}{models.JobQueued, models.JobRunning, models.JobPaused}
+	if filter.ID != 0 {
+		where += ` AND id = ?`
+		args = append(args, filter.ID)
+	}
+	if filter.BatchID != 0 {
+		where += ` AND batch_id = ?`
+		args = append(args, filter.BatchID)
+	}
+	if filter.URL != "" {
+		where += ` AND url = ?`
+		args = append(args, filter.URL)
+	}
+	return queryIDs(s.db, `SELECT id FROM crawl_jobs WHERE `+where+` ORDER BY id`, args...)
+}
+
+// ActiveJobs returns the unfinished jobs and the jobs that finished after
+// since, in submission order.
+func (s *SQLStore) ActiveJobs(since time.Time) ([]models.Job, error) {
+	jobs, err := s.queryJobs(`WHERE state IN (?, ?, ?) OR finished_at >= ? ORDER BY id`,
+		models.JobQueued, models.JobRunning, models.JobPaused, since)
+	if jobs == nil {
+		jobs = []models.Job{}
+	}
+	return jobs, err
+}
+
+// RunningJobs returns the running jobs.
+func (s *SQLStore) RunningJobs() ([]models.Job, error) {
+	return s.queryJobs(`WHERE state = ? ORDER BY id`, models.JobRunning)
+}
+
+// CountJobs returns the number of jobs in a state.
+func (s *SQLStore) CountJobs(state string) (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM crawl_jobs WHERE state = ?`, state).Scan(&n)
+	return n, err
+}
+
+// AverageJobDuration returns the mean run time of the last jobs that
+// finished successfully, or 0 if there are none.
+func (s *SQLStore) AverageJobDuration() (time.Duration, error) {
+	var seconds sql.NullFloat64
+	err := s.db.QueryRow(`
+		SELECT AVG(`+s.dialect.secondsBetween("started_at", "finished_at")+`) FROM (
+			SELECT started_at, finished_at FROM crawl_jobs
+			WHERE state = ? AND started_at IS NOT NULL
+			ORDER BY finished_at DESC LIMIT 50
+		) recent`, models.JobDone).Scan(&seconds)
+	return time.Duration(seconds.Float64 * float64(time.Second)), err
+}
+
+// StateChange is a recorded job transition as a progress event, with the ID
+// of its crawl_job_events row.
+type StateChange struct {
+	ID    int64
+	Event models.ProgressEvent
+}
+
+// StateChangesSince returns the job transitions recorded after the event
+// with ID afterID, oldest first.
+func (s *SQLStore) StateChangesSince(afterID int64) ([]StateChange, error) {
+	rows, err := s.db.Query(`
+		SELECT e.id, e.job_id, j.batch_id, j.url, e.to_state, e.created_at
+		FROM crawl_job_events e JOIN crawl_jobs j ON j.id = e.job_id
+		WHERE e.id > ? ORDER BY e.id LIMIT 1000`, afterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []StateChange
+	for rows.Next() {
+		var (
+			change    StateChange
+			batchID   sql.NullInt64
+			createdAt string
+		)
+		change.Event.Type = "state"
+		if err := rows.Scan(&change.ID, &change.Event.JobID, &batchID, &change.Event.URL,
+			&change.Event.State, &createdAt); err != nil {
+			return nil, err
+		}
+		change.Event.BatchID = batchID.Int64
+		change.Event.Time, _ = time.Parse(timeLayout, createdAt)
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// LastStateChangeID returns the ID of the latest recorded job transition.
+func (s *SQLStore) LastStateChangeID() (int64, error) {
+	var id sql.NullInt64
+	err := s.db.QueryRow(`SELECT MAX(id) FROM crawl_job_events`).Scan(&id)
+	return id.Int64, err
+}
+
+// GetJobEvents returns the state transitions of a job, oldest first.
+func (s *SQLStore) GetJobEvents(id int64) ([]models.JobEvent, error) {
+	rows, err := s.db.Query(`
+		SELECT job_id, from_state, to_state, message, created_at
+		FROM crawl_job_events WHERE job_id = ? ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.JobEvent{}
+	for rows.Next() {
+		var (
+			event         models.JobEvent
+			from, message sql.NullString
+			createdAt     string
+		)
+		if err := rows.Scan(&event.JobID, &from, &event.ToState, &message, &createdAt); err != nil {
+			return nil, err
+		}
+		event.FromState = from.String
+		event.Message = message.String
+		event.CreatedAt, _ = time.Parse(timeLayout, createdAt)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func insertJobEvent(tx *sql.Tx, id int64, from, to, message string, at time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO crawl_job_events (job_id, from_state, to_state, message, created_at)
+		VALUES (?, ?, ?, ?, ?)`, id, from, to, message, at)
+	return err
+}
+
+func (s *SQLStore) queryJobs(where string, args ...interface{}) ([]models.Job, error) {
+	rows, err := s.db.Query(`SELECT `+jobColumns+` FROM crawl_jobs `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []models.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// queryIDs runs a query returning one ID column.
+func queryIDs(q interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}, query string, args ...interface{}) ([]int64, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func scanJob(row interface{ Scan(...interface{}) error }) (models.Job, error) {
+	var (
+		job                  models.Job
+		optionsJSON          []byte
+		batchID, resultID    sql.NullInt64
+		errorMessage         sql.NullString
+		createdAt, updatedAt string
+		startedAt, finished  sql.NullString
+		runAfter, leaseUntil sql.NullString
+		workerID             sql.NullString
+		frontierJSON         []byte
+		phaseJSON            []byte
+	)
+	if err := row.Scan(&job.ID, &batchID, &job.URL, &optionsJSON, &job.State, &job.Attempts, &resultID,
+		&errorMessage, &createdAt, &updatedAt, &startedAt, &finished, &runAfter, &frontierJSON,
+		&workerID, &leaseUntil, &phaseJSON); err != nil {
+		return job, err
+	}
+	if len(optionsJSON) > 0 {
+		json.Unmarshal(optionsJSON, &job.Options)
+	}
+	job.BatchID = batchID.Int64
+	job.ResultID = int(resultID.Int64)
+	job.ErrorMessage = errorMessage.String
+	job.CreatedAt, _ = time.Parse(timeLayout, createdAt)
+	job.UpdatedAt, _ = time.Parse(timeLayout, updatedAt)
+	job.StartedAt = parseNullTime(startedAt)
+	job.FinishedAt = parseNullTime(finished)
+	job.RunAfter = parseNullTime(runAfter)
+	job.WorkerID = workerID.String
+	job.LeaseUntil = parseNullTime(leaseUntil)
+	if len(frontierJSON) > 0 && string(frontierJSON) != "null" {
+		json.Unmarshal(frontierJSON, &job.Frontier)
+	}
+	if len(phaseJSON) > 0 && string(phaseJSON) != "null" {
+		json.Unmarshal(phaseJSON, &job.Phase)
+	}
+	return job, nil
+}
+
+func parseNullTime(s sql.NullString) *time.Time {
+	if !s.Valid {
+		return nil
+	}
+	t, err := time.Parse(timeLayout, s.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,208 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+// newTestStore returns a migrated in-memory SQLite store.
+func newTestStore(t *testing.T) *SQLStore {
+	t.Helper()
+	store, err := OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func mustCreateJob(t *testing.T, s *SQLStore, url string, opts models.CrawlOptions) int64 {
+	t.Helper()
+	id, err := s.CreateJob(url, opts, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func mustClaimJob(t *testing.T, s *SQLStore, workerID string) *models.Job {
+	t.Helper()
+	job, err := s.ClaimJob(workerID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job == nil {
+		t.Fatal("no job to claim")
+	}
+	return job
+}
+
+func TestCreateAndClaimJob(t *testing.T) {
+	s := newTestStore(t)
+	normal := mustCreateJob(t, s, "https://a.example/", models.CrawlOptions{Project: "a"})
+	high := mustCreateJob(t, s, "https://b.example/", models.CrawlOptions{Priority: models.PriorityHigh})
+
+	job, err := s.GetJob(normal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != models.JobQueued || job.URL != "https://a.example/" || time.Since(job.CreatedAt) > time.Minute {
+		t.Fatalf("created job = %+v", job)
+	}
+
+	claimed := mustClaimJob(t, s, "w1")
+	if claimed.ID != high {
+		t.Fatalf("claimed job %d first, want the high priority job %d", claimed.ID, high)
+	}
+	if claimed.State != models.JobRunning || claimed.WorkerID != "w1" || claimed.Attempts != 1 || claimed.LeaseUntil == nil {
+		t.Fatalf("claimed job = %+v", claimed)
+	}
+	if lease := time.Until(*claimed.LeaseUntil); lease < 50*time.Second || lease > 70*time.Second {
+		t.Errorf("lease expires in %v, want about a minute", lease)
+	}
+
+	if claimed := mustClaimJob(t, s, "w2"); claimed.ID != normal {
+		t.Fatalf("claimed job %d, want %d", claimed.ID, normal)
+	}
+	if none, err := s.ClaimJob("w2", time.Minute); err != nil || none != nil {
+		t.Fatalf("claim on an empty queue = %+v, %v", none, err)
+	}
+}
+
+func TestFinishJob(t *testing.T) {
+	s := newTestStore(t)
+	id := mustCreateJob(t, s, "https://a.example/", models.CrawlOptions{})
+	mustClaimJob(t, s, "w1")
+
+	if _, err := s.FinishJob(id, "w2", models.JobDone, ""); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("finish by another worker: got %v, want ErrLeaseLost", err)
+	}
+	state, err := s.FinishJob(id, "w1", models.JobDone, "")
+	if err != nil || state != models.JobDone {
+		t.Fatalf("FinishJob = %q, %v", state, err)
+	}
+	if _, err := s.FinishJob(id, "w1", models.JobDone, ""); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("finish twice: got %v, want ErrLeaseLost", err)
+	}
+
+	events, err := s.GetJobEvents(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var states []string
+	for _, event := range events {
+		states = append(states, event.ToState)
+	}
+	if fmt.Sprint(states) != fmt.Sprint([]string{models.JobQueued, models.JobRunning, models.JobDone}) {
+		t.Errorf("job went through %v", states)
+	}
+}
+
+func TestFinishCanceledJob(t *testing.T) {
+	s := newTestStore(t)
+	id := mustCreateJob(t, s, "https://a.example/", models.CrawlOptions{})
+	mustClaimJob(t, s, "w1")
+
+	if ok, err := s.CancelJob(id); err != nil || !ok {
+		t.Fatalf("CancelJob = %v, %v", ok, err)
+	}
+	renewed, err := s.RenewLeases("w1", []int64{id}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed[id] != models.JobCanceled {
+		t.Errorf("RenewLeases reported %q, want the cancel request", renewed[id])
+	}
+	// A worker that still finishes the job does not overwrite the cancel.
+	if state, err := s.FinishJob(id, "w1", models.JobDone, ""); err != nil || state != models.JobCanceled {
+		t.Errorf("FinishJob = %q, %v; want canceled", state, err)
+	}
+}
+
+func TestRetryJob(t *testing.T) {
+	s := newTestStore(t)
+	id := mustCreateJob(t, s, "https://a.example/", models.CrawlOptions{Mode: "site"})
+	mustClaimJob(t, s, "w1")
+
+	frontier := &models.SiteFrontier{ParentID: 7, Crawled: 3}
+	state, err := s.RetryJob(id, "w1", time.Now().Add(time.Hour), "attempt 1 failed", frontier)
+	if err != nil || state != models.JobQueued {
+		t.Fatalf("RetryJob = %q, %v", state, err)
+	}
+	if early, err := s.ClaimJob("w1", time.Minute); err != nil || early != nil {
+		t.Fatalf("claimed a job before its retry delay: %+v, %v", early, err)
+	}
+
+	job, err := s.GetJob(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.RunAfter == nil || time.Until(*job.RunAfter) < 59*time.Minute {
+		t.Errorf("run after = %v, want in an hour", job.RunAfter)
+	}
+	if job.Frontier == nil || job.Frontier.ParentID != 7 || job.Frontier.Crawled != 3 {
+		t.Errorf("frontier = %+v, want the one passed to RetryJob", job.Frontier)
+	}
+	if job.Attempts != 1 {
+		t.Errorf("attempts = %d, want 1", job.Attempts)
+	}
+}
+
+func TestHoldJob(t *testing.T) {
+	s := newTestStore(t)
+	id := mustCreateJob(t, s, "https://a.example/", models.CrawlOptions{})
+	mustClaimJob(t, s, "w1")
+
+	state, err := s.HoldJob(id, "w1", "interrupted", &models.SiteFrontier{Crawled: 2})
+	if err != nil || state != models.JobQueued {
+		t.Fatalf("HoldJob = %q, %v", state, err)
+	}
+	job := mustClaimJob(t, s, "w2")
+	if job.ID != id || job.Attempts != 1 || job.Frontier == nil || job.Frontier.Crawled != 2 {
+		t.Errorf("held job = %+v, want it claimable again without a used attempt", job)
+	}
+}
+
+func TestCreateBatch(t *testing.T) {
+	s := newTestStore(t)
+	// More URLs than one insert statement takes.
+	urls := make([]string, jobInsertBatch+3)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("https://a.example/%d", i)
+	}
+	batchID, jobIDs, err := s.CreateBatch(models.Batch{Source: "text", Total: len(urls), Accepted: len(urls)}, urls,
+		models.CrawlOptions{Priority: models.PriorityHigh, Project: "shop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobIDs) != len(urls) {
+		t.Fatalf("got %d job IDs, want %d", len(jobIDs), len(urls))
+	}
+	for _, i := range []int{0, len(urls) - 1} {
+		job, err := s.GetJob(jobIDs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.URL != urls[i] || job.BatchID != batchID || job.State != models.JobQueued || job.Options.Project != "shop" {
+			t.Errorf("job %d = %+v", i, job)
+		}
+		if events, _ := s.GetJobEvents(job.ID); len(events) != 1 || events[0].ToState != models.JobQueued {
+			t.Errorf("events of job %d = %+v", i, events)
+		}
+	}
+
+	batch, err := s.GetBatch(batchID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Source != "text" || batch.Accepted != len(urls) {
+		t.Errorf("batch = %+v", batch)
+	}
+	if jobs, _ := s.BatchJobs(batchID); len(jobs) != len(urls) {
+		t.Errorf("batch has %d jobs, want %d", len(jobs), len(urls))
+	}
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+func urlHash(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetLinkCheck returns the cached check of url if it was made at or after since
+func (s *SQLStore) GetLinkCheck(url string, since time.Time) (models.LinkCheck, bool, error) {
+	check := models.LinkCheck{URL: url}
+	var (
+		checkedAtStr  string
+		redirectsJSON []byte
+	)
+	err := s.db.QueryRow(`
+		SELECT broken, status_code, COALESCE(reason, ''), redirects, checked_at FROM link_cache
+		WHERE url_hash = ? AND checked_at >= ?`,
+		urlHash(url), since).Scan(&check.Broken, &check.StatusCode, &check.Reason, &redirectsJSON, &checkedAtStr)
+	if err == sql.ErrNoRows {
+		return models.LinkCheck{}, false, nil
+	}
+	if err != nil {
+		return models.LinkCheck{}, false, err
+	}
+	if check.CheckedAt, err = time.Parse(timeLayout, checkedAtStr); err != nil {
+		return models.LinkCheck{}, false, err
+	}
+	if len(redirectsJSON) > 0 {
+		json.Unmarshal(redirectsJSON, &check.Redirects)
+	}
+	return check, true, nil
+}
+
+// PutLinkCheck inserts or replaces the cached check of a URL
+func (s *SQLStore) PutLinkCheck(check models.LinkCheck) error {
+	redirectsJSON, _ := json.Marshal(check.Redirects)
+	_, err := s.db.Exec(`
+		INSERT INTO link_cache (url_hash, url, broken, status_code, reason, redirects, checked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`+
+		s.dialect.upsert("url_hash", "broken", "status_code", "reason", "redirects", "checked_at"),
+		urlHash(check.URL), check.URL, check.Broken, check.StatusCode, check.Reason, redirectsJSON, check.CheckedAt)
+	return err
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+// linkInsertBatch is the number of links inserted per statement.
+const linkInsertBatch = 200
+
+const linkColumns = `url, anchor, nofollow, sponsored, ugc, is_external, broken, status_code, reason, checked_at`
+
+// insertLinks stores the links of the result with the given ID.
+func insertLinks(tx *sql.Tx, resultID int64, links []models.PageLink) error {
+	for start := 0; start < len(links); start += linkInsertBatch {
+		batch := links[start:min(start+linkInsertBatch, len(links))]
+		rows := make([]string, len(batch))
+		args := make([]interface{}, 0, len(batch)*12)
+		for i, link := range batch {
+			rows[i] = `(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+			var checkedAt interface{}
+			if link.CheckedAt != nil {
+				checkedAt = *link.CheckedAt
+			}
+			args = append(args, resultID, urlHash(link.URL), link.URL, link.Anchor,
+				link.Nofollow, link.Sponsored, link.UGC, link.External, link.Broken,
+				link.StatusCode, link.Reason, checkedAt)
+		}
+		_, err := tx.Exec(`INSERT INTO links (result_id, url_hash, `+linkColumns+`) VALUES `+
+			strings.Join(rows, `, `), args...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResultLinks returns the links of a result in document order.
+func (s *SQLStore) ResultLinks(resultID int64) ([]models.PageLink, error) {
+	rows, err := s.db.Query(`SELECT `+linkColumns+` FROM links WHERE result_id = ? ORDER BY id`, resultID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []models.PageLink{}
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// LinkSources returns the links matching filter with the pages they were
+// found on, across every crawl, newest crawl first.
+func (s *SQLStore) LinkSources(filter LinkFilter) ([]models.LinkSource, error) {
+	where := `WHERE TRUE`
+	var args []interface{}
+	if filter.URL != "" {
+		where += ` AND l.url_hash = ?`
+		args = append(args, urlHash(filter.URL))
+	}
+	if filter.StatusCode != 0 {
+		where += ` AND l.status_code = ?`
+		args = append(args, filter.StatusCode)
+	}
+	if filter.Broken {
+		where += ` AND l.broken = TRUE`
+	}
+	if len(args) == 0 && !filter.Broken {
+		return nil, errors.New("link filter selects every link")
+	}
+	query := `
+		SELECT l.result_id, u.url, u.job_id, u.created_at, l.` + strings.ReplaceAll(linkColumns, `, `, `, l.`) + `
+		FROM links l JOIN urls u ON u.id = l.result_id
+		` + where + ` ORDER BY l.result_id DESC, l.id`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sources := []models.LinkSource{}
+	for rows.Next() {
+		var (
+			source       models.LinkSource
+			jobIDNI      sql.NullInt64
+			crawledAtStr string
+		)
+		source.Link, err = scanLink(rows, &source.ResultID, &source.PageURL, &jobIDNI, &crawledAtStr)
+		if err != nil {
+			return nil, err
+		}
+		if source.CrawledAt, err = time.Parse(timeLayout, crawledAtStr); err != nil {
+			return nil, err
+		}
+		source.JobID = jobIDNI.Int64
+		sources = append(sources, source)
+	}
+	return sources, rows.Err()
+}
+
+// scanLink reads the linkColumns of a row, after the given leading columns.
+func scanLink(rows *sql.Rows, leading ...interface{}) (models.PageLink, error) {
+	var (
+		link        models.PageLink
+		anchorNS    sql.NullString
+		reasonNS    sql.NullString
+		checkedAtNS sql.NullString
+	)
+	dest := append(leading, &link.URL, &anchorNS, &link.Nofollow, &link.Sponsored, &link.UGC,
+		&link.External, &link.Broken, &link.StatusCode, &reasonNS, &checkedAtNS)
+	if err := rows.Scan(dest...); err != nil {
+		return link, err
+	}
+	link.Anchor = anchorNS.String
+	link.Reason = reasonNS.String
+	if checkedAtNS.Valid {
+		checkedAt, err := time.Parse(timeLayout, checkedAtNS.String)
+		if err != nil {
+			return link, err
+		}
+		link.CheckedAt = &checkedAt
+	}
+	return link, nil
+}
@@ -0,0 +1,367 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the migrations of each dialect, in
+// migrations/<dialect>/<version>_<name>.up.sql and .down.sql. Statements
+// end with a semicolon at the end of a line.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a numbered schema change with the SQL to apply and to revert
+// it.
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus is a migration and whether it is applied.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+	// Dirty is set if the migration failed halfway and the schema must be
+	// fixed by hand.
+	Dirty bool
+}
+
+// ErrSchemaOutdated is returned by CheckSchema when migrations are pending
+// and it may not apply them.
+var ErrSchemaOutdated = errors.New("database schema is outdated")
+
+// Migrations returns the migrations of the store's dialect, oldest first.
+func (s *SQLStore) Migrations() ([]Migration, error) {
+	dir := path.Join("migrations", s.dialect.name)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		body, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// MigrationStatus returns every migration known to this build or recorded
+// in schema_migrations, oldest first. Migrations recorded but unknown come
+// from a newer build and have no name.
+func (s *SQLStore) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := s.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(s.dialect.migrationsTable); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT version, dirty, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	status := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		status[i].Migration = m
+	}
+	for rows.Next() {
+		var (
+			version   int
+			dirty     bool
+			appliedAt string
+		)
+		if err := rows.Scan(&version, &dirty, &appliedAt); err != nil {
+			return nil, err
+		}
+		at, _ := time.Parse(timeLayout, appliedAt)
+		for len(status) < version {
+			status = append(status, MigrationStatus{Migration: Migration{Version: len(status) + 1}})
+		}
+		status[version-1].AppliedAt = &at
+		status[version-1].Dirty = dirty
+	}
+	return status, rows.Err()
+}
+
+// SchemaVersion returns the version of the latest applied migration, 0 if
+// there is none.
+func (s *SQLStore) SchemaVersion() (int, error) {
+	status, err := s.MigrationStatus()
+	if err != nil {
+		return 0, err
+	}
+	return appliedVersion(status), nil
+}
+
+func appliedVersion(status []MigrationStatus) int {
+	version := 0
+	for _, m := range status {
+		if m.AppliedAt != nil {
+			version = m.Version
+		}
+	}
+	return version
+}
+
+// CheckSchema makes sure the schema matches this build before the store is
+// used. Pending migrations are applied if migrate is set; otherwise it
+// returns ErrSchemaOutdated. A schema newer than this build or a migration
+// that failed halfway is an error either way.
+func (s *SQLStore) CheckSchema(migrate bool) error {
+	status, err := s.MigrationStatus()
+	if err != nil {
+		return err
+	}
+	if err := checkDirty(status); err != nil {
+		return err
+	}
+	migrations, _ := s.Migrations()
+	version := appliedVersion(status)
+	switch latest := len(migrations); {
+	case version > latest:
+		return fmt.Errorf("database schema version %d is newer than this build (%d)", version, latest)
+	case version == latest:
+		return nil
+	case !migrate:
+		return fmt.Errorf("%w: version %d, this build needs %d", ErrSchemaOutdated, version, latest)
+	}
+	_, err = s.MigrateUp(0)
+	return err
+}
+
+// MigrateUp applies the pending migrations up to version target, or all of
+// them if target is 0, and returns the applied ones.
+func (s *SQLStore) MigrateUp(target int) ([]Migration, error) {
+	var applied []Migration
+	err := s.withMigrationLock(func(status []MigrationStatus) error {
+		version := appliedVersion(status)
+		for _, m := range status {
+			if m.Version <= version || target > 0 && m.Version > target {
+				continue
+			}
+			if m.Version == 1 && s.dialect.adopt != nil {
+				if err := s.dialect.adopt(s.db); err != nil {
+					return fmt.Errorf("adopt existing tables: %w", err)
+				}
+			}
+			if err := s.runMigration(m.Migration, m.up, true); err != nil {
+				return err
+			}
+			applied = append(applied, m.Migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// ErrBaselineMigration is returned by MigrateDown for steps that would
+// revert the first migration. It holds the tables of databases created
+// before migrations, so reverting it would drop their crawl results.
+var ErrBaselineMigration = errors.New("migration 1 is the baseline schema and cannot be reverted")
+
+// MigrateDown reverts the latest steps applied migrations and returns the
+// reverted ones, newest first. It reverts nothing if that would include
+// the baseline migration.
+func (s *SQLStore) MigrateDown(steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := s.withMigrationLock(func(status []MigrationStatus) error {
+		var pending []MigrationStatus
+		for i := len(status) - 1; i >= 0 && len(pending) < steps; i-- {
+			m := status[i]
+			if m.AppliedAt == nil {
+				continue
+			}
+			if m.Version == 1 {
+				return ErrBaselineMigration
+			}
+			if m.down == "" {
+				return fmt.Errorf("migration %d is unknown to this build and cannot be reverted", m.Version)
+			}
+			pending = append(pending, m)
+		}
+		for _, m := range pending {
+			if err := s.runMigration(m.Migration, m.down, false); err != nil {
+				return err
+			}
+			reverted = append(reverted, m.Migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// withMigrationLock calls migrate with the current migration status while
+// no other process migrates.
+func (s *SQLStore) withMigrationLock(migrate func([]MigrationStatus) error) error {
+	if s.dialect.lockMigrations != "" {
+		conn, err := s.db.Conn(context.Background())
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		var locked bool
+		if err := conn.QueryRowContext(context.Background(), s.dialect.lockMigrations).Scan(&locked); err != nil {
+			return err
+		}
+		if !locked {
+			return errors.New("another process is migrating the database")
+		}
+		defer conn.ExecContext(context.Background(), s.dialect.unlockMigrations)
+	}
+
+	status, err := s.MigrationStatus()
+	if err != nil {
+		return err
+	}
+	if err := checkDirty(status); err != nil {
+		return err
+	}
+	return migrate(status)
+}
+
+func checkDirty(status []MigrationStatus) error {
+	for _, m := range status {
+		if m.Dirty {
+			return fmt.Errorf("migration %d failed halfway; fix the schema by hand, then delete its row from schema_migrations", m.Version)
+		}
+	}
+	return nil
+}
+
+// runMigration runs the up or down SQL of a migration and records it. The
+// migration is marked dirty while it runs, so one that fails halfway on a
+// database without transactional DDL blocks further migrations.
+func (s *SQLStore) runMigration(m Migration, script string, up bool) error {
+	direction := "down"
+	if up {
+		direction = "up"
+		if _, err := s.db.Exec(`INSERT INTO schema_migrations (version, name, dirty, applied_at) VALUES (?, ?, TRUE, ?)`,
+			m.Version, m.Name, time.Now()); err != nil {
+			return err
+		}
+	} else if _, err := s.db.Exec(`UPDATE schema_migrations SET dirty = TRUE WHERE version = ?`, m.Version); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, stmt := range splitStatements(script) {
+		if _, err = tx.Exec(stmt); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		if s.dialect.transactionalDDL {
+			s.restoreMigration(m, up)
+		}
+		return fmt.Errorf("migration %d_%s %s: %w", m.Version, m.Name, direction, err)
+	}
+
+	if up {
+		_, err = s.db.Exec(`UPDATE schema_migrations SET dirty = FALSE WHERE version = ?`, m.Version)
+	} else {
+		_, err = s.db.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+	}
+	if err == nil {
+		log.Printf("Migrated %s: %d_%s\n", direction, m.Version, m.Name)
+	}
+	return err
+}
+
+// restoreMigration undoes the dirty mark of a migration that was rolled
+// back.
+func (s *SQLStore) restoreMigration(m Migration, up bool) {
+	var err error
+	if up {
+		_, err = s.db.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+	} else {
+		_, err = s.db.Exec(`UPDATE schema_migrations SET dirty = FALSE WHERE version = ?`, m.Version)
+	}
+	if err != nil {
+		log.Printf("Failed to restore the record of migration %d: %v\n", m.Version, err)
+	}
+}
+
+// splitStatements splits a migration script into statements, dropping
+// comment lines.
+func splitStatements(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+	)
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
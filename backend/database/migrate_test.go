@@ -0,0 +1,75 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"scrawling_dashboard/backend/models"
+)
+
+func TestMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawl.db")
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := s.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := len(migrations)
+	if version, err := s.SchemaVersion(); err != nil || version != latest {
+		t.Fatalf("SchemaVersion = %d, %v; want %d", version, err, latest)
+	}
+	if err := s.CheckSchema(false); err != nil {
+		t.Fatal(err)
+	}
+
+	if reverted, err := s.MigrateDown(latest); !errors.Is(err, ErrBaselineMigration) || len(reverted) != 0 {
+		t.Fatalf("reverting the baseline = %v, %v; want ErrBaselineMigration and nothing reverted", reverted, err)
+	}
+	reverted, err := s.MigrateDown(latest - 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != latest-1 || reverted[0].Version != latest {
+		t.Fatalf("reverted %+v, want every migration after the baseline, newest first", reverted)
+	}
+	if _, err := s.CreateJob("https://a.example/", models.CrawlOptions{}, 0); err != nil {
+		t.Fatalf("baseline tables are gone: %v", err)
+	}
+	if err := s.CheckSchema(false); !errors.Is(err, ErrSchemaOutdated) {
+		t.Fatalf("CheckSchema without migrating = %v, want ErrSchemaOutdated", err)
+	}
+	if err := s.CheckSchema(true); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := s.SchemaVersion(); version != latest {
+		t.Fatalf("SchemaVersion = %d after migrating, want %d", version, latest)
+	}
+	s.Close()
+
+	s, err = OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if applied, err := s.MigrateUp(0); err != nil || len(applied) != 0 {
+		t.Errorf("MigrateUp on an up to date schema = %+v, %v", applied, err)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := "-- comment\nCREATE TABLE a (\n  id INT\n);\n\nDROP INDEX b;\nSELECT 1"
+	got := splitStatements(script)
+	want := []string{"CREATE TABLE a (\n  id INT\n)", "DROP INDEX b", "SELECT 1"}
+	if len(got) != len(want) {
+		t.Fatalf("splitStatements = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("statement %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,3 @@
+-- The baseline is never reverted; MigrateDown refuses to. Its tables include
+-- urls as adopted from databases created before migrations, so dropping them
+-- would lose every crawl result recorded so far.
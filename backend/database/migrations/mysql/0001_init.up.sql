@@ -0,0 +1,116 @@
+-- The schema as it was before versioned migrations. Tables are only created
+-- if missing so that databases of that time can adopt it.
+
+CREATE TABLE IF NOT EXISTS urls (
+	id INT AUTO_INCREMENT PRIMARY KEY,
+	url TEXT NOT NULL,
+	html_version VARCHAR(50),
+	title TEXT,
+	headings JSON,
+	internal_links INT DEFAULT 0,
+	external_links INT DEFAULT 0,
+	broken_links JSON,
+	has_login_form BOOLEAN DEFAULT FALSE,
+	status ENUM('queued', 'running', 'done', 'error', 'blocked', 'canceled') DEFAULT 'done',
+	error_message TEXT,
+	depth INT DEFAULT 0,
+	parent_id INT NULL,
+	redirects JSON,
+	link_redirects JSON,
+	job_id BIGINT NULL,
+	timings JSON,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_urls_job (job_id)
+);
+
+CREATE TABLE IF NOT EXISTS link_cache (
+	url_hash CHAR(64) PRIMARY KEY,
+	url TEXT NOT NULL,
+	broken BOOLEAN NOT NULL,
+	status_code INT DEFAULT 0,
+	reason VARCHAR(32),
+	redirects JSON,
+	checked_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS crawl_jobs (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	url TEXT NOT NULL,
+	options JSON,
+	state VARCHAR(16) NOT NULL DEFAULT 'queued',
+	attempts INT NOT NULL DEFAULT 0,
+	result_id INT NULL,
+	error_message TEXT,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	started_at DATETIME NULL,
+	finished_at DATETIME NULL,
+	run_after DATETIME NULL,
+	batch_id BIGINT NULL,
+	frontier JSON,
+	priority INT NOT NULL DEFAULT 1,
+	project VARCHAR(255) NOT NULL DEFAULT 'default',
+	worker_id VARCHAR(64) NULL,
+	lease_until DATETIME NULL,
+	interrupt_to VARCHAR(16) NULL,
+	phase JSON,
+	INDEX idx_crawl_jobs_state (state),
+	INDEX idx_crawl_jobs_batch (batch_id),
+	INDEX idx_crawl_jobs_claim (state, priority, project)
+);
+
+CREATE TABLE IF NOT EXISTS crawl_job_events (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	job_id BIGINT NOT NULL,
+	from_state VARCHAR(16),
+	to_state VARCHAR(16) NOT NULL,
+	message TEXT,
+	created_at DATETIME NOT NULL,
+	INDEX idx_crawl_job_events_job (job_id)
+);
+
+CREATE TABLE IF NOT EXISTS crawl_batches (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	source VARCHAR(16) NOT NULL,
+	total INT NOT NULL DEFAULT 0,
+	accepted INT NOT NULL DEFAULT 0,
+	rejected INT NOT NULL DEFAULT 0,
+	paused BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS settings (
+	name VARCHAR(64) PRIMARY KEY,
+	value TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS crawl_project_turns (
+	project VARCHAR(255) PRIMARY KEY,
+	claimed_at DATETIME(3) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS crawl_workers (
+	id VARCHAR(64) PRIMARY KEY,
+	host VARCHAR(255) NOT NULL,
+	slots INT NOT NULL,
+	running INT NOT NULL DEFAULT 0,
+	stats JSON,
+	started_at DATETIME NOT NULL,
+	seen_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS schedules (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL DEFAULT '',
+	cron_expr VARCHAR(255) NOT NULL,
+	url TEXT NOT NULL,
+	options JSON,
+	missed_policy VARCHAR(16) NOT NULL DEFAULT 'skip',
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	next_run_at DATETIME NOT NULL,
+	last_run_at DATETIME NULL,
+	last_job_id BIGINT NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	INDEX idx_schedules_due (enabled, next_run_at)
+);
@@ -0,0 +1,22 @@
+-- Every link of every crawled page, so that the pages linking to a URL can
+-- be found across crawls. url_hash is the SHA-256 of url, which is too long
+-- to index.
+
+CREATE TABLE links (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	result_id INT NOT NULL,
+	url TEXT NOT NULL,
+	url_hash CHAR(64) NOT NULL,
+	anchor TEXT,
+	nofollow BOOLEAN NOT NULL DEFAULT FALSE,
+	sponsored BOOLEAN NOT NULL DEFAULT FALSE,
+	ugc BOOLEAN NOT NULL DEFAULT FALSE,
+	is_external BOOLEAN NOT NULL DEFAULT FALSE,
+	broken BOOLEAN NOT NULL DEFAULT FALSE,
+	status_code INT NOT NULL DEFAULT 0,
+	reason VARCHAR(32),
+	checked_at DATETIME NULL,
+	INDEX idx_links_result (result_id),
+	INDEX idx_links_url (url_hash),
+	INDEX idx_links_status (status_code)
+);
@@ -0,0 +1 @@
+DROP INDEX idx_urls_url ON urls;
@@ -0,0 +1,4 @@
+-- Finds the runs of a URL for its crawl history. TEXT columns are indexed
+-- by a prefix.
+
+CREATE INDEX idx_urls_url ON urls (url(255));
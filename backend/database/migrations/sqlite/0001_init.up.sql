@@ -0,0 +1,122 @@
+-- The MySQL schema in SQLite terms. Times are TEXT, which keeps the driver
+-- from converting them.
+
+CREATE TABLE IF NOT EXISTS urls (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL,
+	html_version TEXT,
+	title TEXT,
+	headings TEXT,
+	internal_links INTEGER DEFAULT 0,
+	external_links INTEGER DEFAULT 0,
+	broken_links TEXT,
+	has_login_form INTEGER DEFAULT 0,
+	status TEXT DEFAULT 'done',
+	error_message TEXT,
+	depth INTEGER DEFAULT 0,
+	parent_id INTEGER NULL,
+	redirects TEXT,
+	link_redirects TEXT,
+	job_id INTEGER NULL,
+	timings TEXT,
+	created_at TEXT DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_urls_job ON urls (job_id);
+
+CREATE TABLE IF NOT EXISTS link_cache (
+	url_hash TEXT PRIMARY KEY,
+	url TEXT NOT NULL,
+	broken INTEGER NOT NULL,
+	status_code INTEGER DEFAULT 0,
+	reason TEXT,
+	redirects TEXT,
+	checked_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS crawl_jobs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL,
+	options TEXT,
+	state TEXT NOT NULL DEFAULT 'queued',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	result_id INTEGER NULL,
+	error_message TEXT,
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL,
+	started_at TEXT NULL,
+	finished_at TEXT NULL,
+	run_after TEXT NULL,
+	batch_id INTEGER NULL,
+	frontier TEXT,
+	priority INTEGER NOT NULL DEFAULT 1,
+	project TEXT NOT NULL DEFAULT 'default',
+	worker_id TEXT NULL,
+	lease_until TEXT NULL,
+	interrupt_to TEXT NULL,
+	phase TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_crawl_jobs_state ON crawl_jobs (state);
+
+CREATE INDEX IF NOT EXISTS idx_crawl_jobs_batch ON crawl_jobs (batch_id);
+
+CREATE INDEX IF NOT EXISTS idx_crawl_jobs_claim ON crawl_jobs (state, priority, project);
+
+CREATE TABLE IF NOT EXISTS crawl_job_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	job_id INTEGER NOT NULL,
+	from_state TEXT,
+	to_state TEXT NOT NULL,
+	message TEXT,
+	created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_crawl_job_events_job ON crawl_job_events (job_id);
+
+CREATE TABLE IF NOT EXISTS crawl_batches (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	source TEXT NOT NULL,
+	total INTEGER NOT NULL DEFAULT 0,
+	accepted INTEGER NOT NULL DEFAULT 0,
+	rejected INTEGER NOT NULL DEFAULT 0,
+	paused INTEGER NOT NULL DEFAULT 0,
+	created_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS settings (
+	name TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS crawl_project_turns (
+	project TEXT PRIMARY KEY,
+	claimed_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS crawl_workers (
+	id TEXT PRIMARY KEY,
+	host TEXT NOT NULL,
+	slots INTEGER NOT NULL,
+	running INTEGER NOT NULL DEFAULT 0,
+	stats TEXT,
+	started_at TEXT NOT NULL,
+	seen_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS schedules (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL DEFAULT '',
+	cron_expr TEXT NOT NULL,
+	url TEXT NOT NULL,
+	options TEXT,
+	missed_policy TEXT NOT NULL DEFAULT 'skip',
+	enabled INTEGER NOT NULL DEFAULT 1,
+	next_run_at TEXT NOT NULL,
+	last_run_at TEXT NULL,
+	last_job_id INTEGER NULL,
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedules_due ON schedules (enabled, next_run_at);
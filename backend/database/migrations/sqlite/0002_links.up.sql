@@ -0,0 +1,24 @@
+-- Every link of every crawled page, so that the pages linking to a URL can
+-- be found across crawls. url_hash is the SHA-256 of url, as in MySQL.
+
+CREATE TABLE links (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	result_id INTEGER NOT NULL,
+	url TEXT NOT NULL,
+	url_hash TEXT NOT NULL,
+	anchor TEXT,
+	nofollow INTEGER NOT NULL DEFAULT 0,
+	sponsored INTEGER NOT NULL DEFAULT 0,
+	ugc INTEGER NOT NULL DEFAULT 0,
+	is_external INTEGER NOT NULL DEFAULT 0,
+	broken INTEGER NOT NULL DEFAULT 0,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	reason TEXT,
+	checked_at TEXT NULL
+);
+
+CREATE INDEX idx_links_result ON links (result_id);
+
+CREATE INDEX idx_links_url ON links (url_hash);
+
+CREATE INDEX idx_links_status ON links (status_code);
@@ -0,0 +1,3 @@
+-- Finds the runs of a URL for its crawl history.
+
+CREATE INDEX idx_urls_url ON urls (url);
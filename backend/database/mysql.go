@@ -3,22 +3,20 @@ package database
 import (
 	"crypto/tls"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strings"
-	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	driver "github.com/go-sql-driver/mysql"
-
-	"scrawling_dashboard/backend/models"
 )
 
-var DB *sql.DB
-
-func ConnectMySQL() {
+// ConnectMySQL opens the store on the MySQL server of MYSQL_DSN, creating
+// the scrawling_db database if needed. It exits if the server cannot be
+// reached.
+func ConnectMySQL() *SQLStore {
 	dsn := os.Getenv("MYSQL_DSN")
 
 	if dsn == "" {
@@ -40,13 +38,13 @@ func ConnectMySQL() {
 		}
 	}
 
-	db, err := sql.Open("mysql", dsn)
+	server, err := sql.Open("mysql", dsn)
 	if err != nil {
 		log.Fatalf("Error connecting to MySQL: %v", err)
 	}
-	defer db.Close()
+	defer server.Close()
 
-	_, err = db.Exec("CREATE DATABASE IF NOT EXISTS scrawling_db")
+	_, err = server.Exec("CREATE DATABASE IF NOT EXISTS scrawling_db")
 	if err != nil {
 		log.Fatalf("Failed to create database: %v", err)
 	}
@@ -56,69 +54,85 @@ func ConnectMySQL() {
 		dsnWithDB += "?" + strings.Split(dsn, "?")[1]
 	}
 
-	DB, err = sql.Open("mysql", dsnWithDB)
+	db, err := sql.Open("mysql", dsnWithDB)
 	if err != nil {
 		log.Fatalf("Error connecting to scrawling_db: %v", err)
 	}
 
-	if err := DB.Ping(); err != nil {
+	if err := db.Ping(); err != nil {
 		log.Fatalf("MySQL ping failed: %v", err)
 	}
 
 	fmt.Println("Connected to MySQL successfully")
 
-	createTable := `
-	CREATE TABLE IF NOT EXISTS urls (
-		id INT AUTO_INCREMENT PRIMARY KEY,
-		url TEXT NOT NULL,
-		html_version VARCHAR(50),
-		title TEXT,
-		headings JSON,
-		internal_links INT DEFAULT 0,
-		external_links INT DEFAULT 0,
-		broken_links JSON,
-		has_login_form BOOLEAN DEFAULT FALSE,
-		status ENUM('queued', 'running', 'done', 'error') DEFAULT 'done',
-		error_message TEXT,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-	_, err = DB.Exec(createTable)
-	if err != nil {
-		log.Fatalf("Failed to create table: %v", err)
+	return &SQLStore{db: db, dialect: mysqlDialect}
+}
+
+// upgradeLegacySchema adds the columns that tables created by versions
+// before migrations may lack. Migration 1 then creates the missing tables.
+func upgradeLegacySchema(db *sql.DB) error {
+	for _, col := range []struct{ table, name, definition string }{
+		{"urls", "depth", "INT DEFAULT 0"},
+		{"urls", "parent_id", "INT NULL"},
+		{"urls", "redirects", "JSON"},
+		{"urls", "link_redirects", "JSON"},
+		{"urls", "job_id", "BIGINT NULL, ADD INDEX idx_urls_job (job_id)"},
+		{"urls", "timings", "JSON"},
+		{"crawl_jobs", "run_after", "DATETIME NULL"},
+		{"crawl_jobs", "batch_id", "BIGINT NULL, ADD INDEX idx_crawl_jobs_batch (batch_id)"},
+		{"crawl_jobs", "frontier", "JSON"},
+		{"crawl_jobs", "priority", "INT NOT NULL DEFAULT 1"},
+		{"crawl_jobs", "project", "VARCHAR(255) NOT NULL DEFAULT 'default', ADD INDEX idx_crawl_jobs_claim (state, priority, project)"},
+		{"crawl_jobs", "worker_id", "VARCHAR(64) NULL"},
+		{"crawl_jobs", "lease_until", "DATETIME NULL"},
+		{"crawl_jobs", "interrupt_to", "VARCHAR(16) NULL"},
+		{"crawl_jobs", "phase", "JSON"},
+		{"crawl_batches", "paused", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"link_cache", "redirects", "JSON"},
+		{"link_cache", "reason", "VARCHAR(32)"},
+	} {
+		if err := ensureColumn(db, col.table, col.name, col.definition); err != nil {
+			return fmt.Errorf("add column %s.%s: %w", col.table, col.name, err)
+		}
 	}
+	if err := ensureColumnType(db, "urls", "status",
+		"enum('queued','running','done','error','blocked','canceled')",
+		"ENUM('queued', 'running', 'done', 'error', 'blocked', 'canceled') DEFAULT 'done'"); err != nil {
+		return fmt.Errorf("update urls.status: %w", err)
+	}
+	return nil
 }
 
-// InsertCrawlResult inserts a result into the database
-func InsertCrawlResult(result *models.CrawlResult) error {
-	headingsJSON, _ := json.Marshal(result.Headings)
-	brokenLinksJSON, _ := json.Marshal(result.BrokenLinks)
-
-	query := `
-		INSERT INTO urls (
-			url, html_version, title, headings, internal_links, external_links,
-			broken_links, has_login_form, status, error_message, created_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-
-	_, err := DB.Exec(
-		query,
-		result.URL,
-		result.HTMLVersion,
-		result.Title,
-		headingsJSON,
-		result.InternalLinks,
-		result.ExternalLinks,
-		brokenLinksJSON,
-		result.HasLoginForm,
-		"queued",
-		"",
-		time.Now(),
-	)
+// ensureColumnType changes the definition of a column whose type differs
+// from columnType, e.g. to add values to an ENUM. Missing tables are
+// skipped.
+func ensureColumnType(db *sql.DB, table, column, columnType, definition string) error {
+	var current string
+	err := db.QueryRow(`
+		SELECT COLUMN_TYPE FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`,
+		table, column).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil || strings.EqualFold(current, columnType) {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", table, column, definition))
 	return err
 }
 
-// UpdateCrawlStatus updates the status of a URL in the database
-func UpdateCrawlStatus(url, status string) error {
-	query := `UPDATE urls SET status = ? WHERE url = ?`
-	_, err := DB.Exec(query, status, url)
+// ensureColumn adds a column to an existing table if it is missing.
+// Missing tables are skipped.
+func ensureColumn(db *sql.DB, table, column, definition string) error {
+	var tables, count int
+	err := db.QueryRow(`
+		SELECT COUNT(*), COUNT(CASE WHEN COLUMN_NAME = ? THEN 1 END) FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?`,
+		column, table).Scan(&tables, &count)
+	if err != nil || tables == 0 || count > 0 {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
 	return err
-}
\ No newline at end of file
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+const resultColumns = `id, url, html_version, title, headings, internal_links, external_links,
+	broken_links, has_login_form, status, error_message, depth, parent_id,
+	redirects, link_redirects, job_id, timings, created_at`
+
+// ListResults returns the results matching filter, newest first.
+func (s *SQLStore) ListResults(filter ResultFilter) ([]models.Result, error) {
+	where := `WHERE TRUE`
+	var args []interface{}
+	if filter.SiteID != 0 {
+		where += ` AND (id = ? OR parent_id = ?)`
+		args = append(args, filter.SiteID, filter.SiteID)
+	}
+	if filter.JobID != 0 {
+		where += ` AND job_id = ?`
+		args = append(args, filter.JobID)
+	}
+	if filter.URL != "" {
+		where += ` AND url = ?`
+		args = append(args, filter.URL)
+	}
+	where += ` ORDER BY created_at DESC, id DESC`
+	if filter.Limit > 0 {
+		where += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+	return s.queryResults(where, args...)
+}
+
+// GetResult returns the result with the given ID.
+func (s *SQLStore) GetResult(id int64) (models.Result, error) {
+	return scanResult(s.db.QueryRow(`SELECT `+resultColumns+` FROM urls WHERE id = ?`, id))
+}
+
+// JobResults returns the results stored by a job in the order they were
+// crawled.
+func (s *SQLStore) JobResults(jobID int64) ([]models.Result, error) {
+	return s.queryResults(`WHERE job_id = ? ORDER BY id`, jobID)
+}
+
+// queryResults returns the urls rows matching where. Rows that cannot be
+// read are skipped.
+func (s *SQLStore) queryResults(where string, args ...interface{}) ([]models.Result, error) {
+	rows, err := s.db.Query(`SELECT `+resultColumns+` FROM urls `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []models.Result{}
+	for rows.Next() {
+		result, err := scanResult(rows)
+		if err != nil {
+			log.Printf("Row scan failed: %v\n", err)
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func scanResult(row interface{ Scan(...interface{}) error }) (models.Result, error) {
+	var (
+		result          models.Result
+		htmlVersionNS   sql.NullString
+		titleNS         sql.NullString
+		headingsJSON    []byte
+		brokenLinksJSON []byte
+		errorMessageNS  sql.NullString
+		depthNI         sql.NullInt64
+		parentIDNI      sql.NullInt64
+		redirectsJSON   []byte
+		linkRedirsJSON  []byte
+		jobIDNI         sql.NullInt64
+		timingsJSON     []byte
+		createdAtStr    string
+	)
+	if err := row.Scan(&result.ID, &result.URL, &htmlVersionNS, &titleNS, &headingsJSON,
+		&result.InternalLinks, &result.ExternalLinks, &brokenLinksJSON, &result.HasLoginForm,
+		&result.Status, &errorMessageNS, &depthNI, &parentIDNI, &redirectsJSON, &linkRedirsJSON,
+		&jobIDNI, &timingsJSON, &createdAtStr); err != nil {
+		return result, err
+	}
+
+	if err := json.Unmarshal(headingsJSON, &result.Headings); err != nil {
+		log.Printf("Unmarshal headings failed: %v\n", err)
+		result.Headings = map[string]int{}
+	}
+	if err := json.Unmarshal(brokenLinksJSON, &result.BrokenLinks); err != nil {
+		log.Printf("Unmarshal broken links failed: %v\n", err)
+		result.BrokenLinks = []models.BrokenLink{}
+	}
+	if len(redirectsJSON) > 0 {
+		json.Unmarshal(redirectsJSON, &result.Redirects)
+	}
+	if len(linkRedirsJSON) > 0 {
+		json.Unmarshal(linkRedirsJSON, &result.LinkRedirects)
+	}
+	if len(timingsJSON) > 0 {
+		json.Unmarshal(timingsJSON, &result.Timings)
+	}
+	if result.LinkRedirects == nil {
+		result.LinkRedirects = []models.RedirectChain{}
+	}
+
+	createdAt, err := time.Parse(timeLayout, createdAtStr)
+	if err != nil {
+		return result, err
+	}
+	result.HTMLVersion = htmlVersionNS.String
+	result.Title = titleNS.String
+	result.ErrorMessage = errorMessageNS.String
+	result.Depth = int(depthNI.Int64)
+	result.ParentID = int(parentIDNI.Int64)
+	result.JobID = jobIDNI.Int64
+	result.CreatedAt = createdAt
+	return result, nil
+}
+
+// InsertCrawlResult inserts a result and its links into the database and
+// returns its ID
+func (s *SQLStore) InsertCrawlResult(result *models.CrawlResult) (int64, error) {
+	headingsJSON, _ := json.Marshal(result.Headings)
+	brokenLinksJSON, _ := json.Marshal(result.BrokenLinks)
+	redirectsJSON, _ := json.Marshal(result.Redirects)
+	linkRedirectsJSON, _ := json.Marshal(result.LinkRedirects)
+	timingsJSON, _ := json.Marshal(result.Timings)
+
+	status := result.Status
+	if status == "" {
+		status = "done"
+	}
+	var parentID, jobID sql.NullInt64
+	if result.ParentID > 0 {
+		parentID = sql.NullInt64{Int64: int64(result.ParentID), Valid: true}
+	}
+	if result.JobID > 0 {
+		jobID = sql.NullInt64{Int64: result.JobID, Valid: true}
+	}
+
+	query := `
+		INSERT INTO urls (
+			url, html_version, title, headings, internal_links, external_links,
+			broken_links, has_login_form, status, error_message, depth, parent_id,
+			redirects, link_redirects, job_id, timings, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		query,
+		result.URL,
+		result.HTMLVersion,
+		result.Title,
+		headingsJSON,
+		result.InternalLinks,
+		result.ExternalLinks,
+		brokenLinksJSON,
+		result.HasLoginForm,
+		status,
+		result.ErrorMessage,
+		result.Depth,
+		parentID,
+		redirectsJSON,
+		linkRedirectsJSON,
+		jobID,
+		timingsJSON,
+		time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := insertLinks(tx, id, result.Links); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// SetResultTimings replaces the phase timings of a result, e.g. to add the
+// duration of its own insert
+func (s *SQLStore) SetResultTimings(id int64, timings *models.PhaseTimings) error {
+	timingsJSON, _ := json.Marshal(timings)
+	_, err := s.db.Exec(`UPDATE urls SET timings = ? WHERE id = ?`, timingsJSON, id)
+	return err
+}
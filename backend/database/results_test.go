@@ -0,0 +1,150 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+func TestInsertCrawlResult(t *testing.T) {
+	s := newTestStore(t)
+	jobID := mustCreateJob(t, s, "https://a.example/", models.CrawlOptions{})
+	checkedAt := time.Now()
+	result := &models.CrawlResult{
+		URL:           "https://a.example/",
+		JobID:         jobID,
+		Status:        models.JobDone,
+		Title:         "A",
+		Headings:      map[string]int{"h1": 1, "h2": 3},
+		HasLoginForm:  true,
+		InternalLinks: 2,
+		BrokenLinks:   []models.BrokenLink{{URL: "https://a.example/gone", StatusCode: 404}},
+		Links: []models.PageLink{
+			{URL: "https://a.example/ok", Anchor: "OK", StatusCode: 200, CheckedAt: &checkedAt},
+			{URL: "https://a.example/gone", Anchor: "Gone", Nofollow: true, Broken: true, StatusCode: 404, CheckedAt: &checkedAt},
+		},
+	}
+	id, err := s.InsertCrawlResult(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetJobResult(jobID, id); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := s.GetResult(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Title != "A" || !stored.HasLoginForm || stored.Headings["h2"] != 3 || len(stored.BrokenLinks) != 1 || stored.CreatedAt.IsZero() {
+		t.Errorf("stored result = %+v", stored)
+	}
+	if results, err := s.JobResults(jobID); err != nil || len(results) != 1 || results[0].ID != stored.ID {
+		t.Errorf("JobResults = %+v, %v", results, err)
+	}
+	if job, _ := s.GetJob(jobID); int64(job.ResultID) != id {
+		t.Errorf("job result = %d, want %d", job.ResultID, id)
+	}
+
+	links, err := s.ResultLinks(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 2 || links[0].Anchor != "OK" || !links[1].Nofollow || !links[1].Broken || links[1].CheckedAt == nil {
+		t.Errorf("links = %+v", links)
+	}
+}
+
+func TestListResultsBySite(t *testing.T) {
+	s := newTestStore(t)
+	parent, err := s.InsertCrawlResult(&models.CrawlResult{URL: "https://a.example/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, url := range []string{"https://a.example/a", "https://a.example/b"} {
+		if _, err := s.InsertCrawlResult(&models.CrawlResult{URL: url, ParentID: int(parent), Depth: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.InsertCrawlResult(&models.CrawlResult{URL: "https://b.example/"}); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := s.ListResults(ResultFilter{SiteID: parent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Errorf("site %d has %d results, want the start page and its 2 pages", parent, len(results))
+	}
+	if all, _ := s.ListResults(ResultFilter{}); len(all) != 4 {
+		t.Errorf("got %d results without a filter, want 4", len(all))
+	}
+}
+
+func TestListResultsByURL(t *testing.T) {
+	s := newTestStore(t)
+	var ids []int64
+	for _, url := range []string{"https://a.example/", "https://b.example/", "https://a.example/"} {
+		id, err := s.InsertCrawlResult(&models.CrawlResult{URL: url})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	results, err := s.ListResults(ResultFilter{URL: "https://a.example/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Newest first.
+	if len(results) != 2 || int64(results[0].ID) != ids[2] || int64(results[1].ID) != ids[0] {
+		t.Errorf("results = %+v, want %d and %d", results, ids[2], ids[0])
+	}
+	if results, _ := s.ListResults(ResultFilter{URL: "https://a.example/", Limit: 1}); len(results) != 1 {
+		t.Errorf("got %d results, want the limit of 1", len(results))
+	}
+}
+
+func TestLinkSources(t *testing.T) {
+	s := newTestStore(t)
+	// More links than one insert statement takes.
+	var links []models.PageLink
+	for len(links) < 450 {
+		links = append(links,
+			models.PageLink{URL: "https://a.example/ok", StatusCode: 200},
+			models.PageLink{URL: "https://a.example/gone", Broken: true, StatusCode: 404})
+	}
+	first, err := s.InsertCrawlResult(&models.CrawlResult{URL: "https://a.example/", Links: links[:2]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.InsertCrawlResult(&models.CrawlResult{URL: "https://a.example/", Links: links})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := s.ResultLinks(second); len(stored) != len(links) {
+		t.Fatalf("stored %d links, want %d", len(stored), len(links))
+	}
+
+	sources, err := s.LinkSources(LinkFilter{URL: "https://a.example/gone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != len(links)/2+1 || sources[0].ResultID != second || sources[len(sources)-1].ResultID != first {
+		t.Fatalf("got %d sources, want %d, newest first", len(sources), len(links)/2+1)
+	}
+	if sources[0].PageURL != "https://a.example/" || sources[0].CrawledAt.IsZero() {
+		t.Errorf("source = %+v", sources[0])
+	}
+	if sources, _ := s.LinkSources(LinkFilter{StatusCode: 404, Limit: 3}); len(sources) != 3 {
+		t.Errorf("got %d sources, want the limit of 3", len(sources))
+	}
+	if sources, _ := s.LinkSources(LinkFilter{Broken: true}); len(sources) != len(links)/2+1 {
+		t.Errorf("got %d broken links, want %d", len(sources), len(links)/2+1)
+	}
+	if _, err := s.LinkSources(LinkFilter{}); err == nil {
+		t.Error("an empty filter is accepted")
+	}
+}
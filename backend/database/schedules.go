@@ -0,0 +1,133 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+const scheduleColumns = `id, name, cron_expr, url, options, missed_policy, enabled,
+	next_run_at, last_run_at, last_job_id, created_at, updated_at`
+
+// CreateSchedule stores a new schedule and returns its ID
+func (s *SQLStore) CreateSchedule(schedule models.Schedule) (int64, error) {
+	optionsJSON, _ := json.Marshal(schedule.Options)
+	now := time.Now()
+	res, err := s.db.Exec(`
+		INSERT INTO schedules (name, cron_expr, url, options, missed_policy, enabled,
+			next_run_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		schedule.Name, schedule.Cron, schedule.URL, optionsJSON, schedule.MissedPolicy, schedule.Enabled,
+		schedule.NextRunAt, now, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// UpdateSchedule replaces the settings and next run of a schedule
+func (s *SQLStore) UpdateSchedule(schedule models.Schedule) error {
+	optionsJSON, _ := json.Marshal(schedule.Options)
+	_, err := s.db.Exec(`
+		UPDATE schedules SET name = ?, cron_expr = ?, url = ?, options = ?, missed_policy = ?,
+			enabled = ?, next_run_at = ?, updated_at = ?
+		WHERE id = ?`,
+		schedule.Name, schedule.Cron, schedule.URL, optionsJSON, schedule.MissedPolicy, schedule.Enabled,
+		schedule.NextRunAt, time.Now(), schedule.ID)
+	return err
+}
+
+// DeleteSchedule removes a schedule. Jobs it already enqueued are kept. It
+// returns sql.ErrNoRows if there is none.
+func (s *SQLStore) DeleteSchedule(id int64) error {
+	res, err := s.db.Exec(`DELETE FROM schedules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		err = sql.ErrNoRows
+	}
+	return err
+}
+
+// GetSchedule returns a schedule by ID. It returns sql.ErrNoRows if there is
+// none.
+func (s *SQLStore) GetSchedule(id int64) (models.Schedule, error) {
+	return scanSchedule(s.db.QueryRow(`SELECT `+scheduleColumns+` FROM schedules WHERE id = ?`, id))
+}
+
+// ListSchedules returns every schedule in creation order.
+func (s *SQLStore) ListSchedules() ([]models.Schedule, error) {
+	return s.querySchedules(`ORDER BY id`)
+}
+
+// DueSchedules returns the enabled schedules whose next run is at or before
+// now.
+func (s *SQLStore) DueSchedules(now time.Time) ([]models.Schedule, error) {
+	return s.querySchedules(`WHERE enabled = TRUE AND next_run_at <= ? ORDER BY next_run_at`, now)
+}
+
+// ClaimScheduleRun moves a schedule from the run planned at due to next. It
+// reports false if another process claimed that run first.
+func (s *SQLStore) ClaimScheduleRun(id int64, due, next time.Time) (bool, error) {
+	res, err := s.db.Exec(`
+		UPDATE schedules SET next_run_at = ?, updated_at = ?
+		WHERE id = ? AND next_run_at = ?`, next, time.Now(), id, due)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SetScheduleRun records the job enqueued by the latest run of a schedule
+func (s *SQLStore) SetScheduleRun(id int64, ranAt time.Time, jobID int64) error {
+	_, err := s.db.Exec(`UPDATE schedules SET last_run_at = ?, last_job_id = ? WHERE id = ?`,
+		ranAt, jobID, id)
+	return err
+}
+
+func (s *SQLStore) querySchedules(where string, args ...interface{}) ([]models.Schedule, error) {
+	rows, err := s.db.Query(`SELECT `+scheduleColumns+` FROM schedules `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []models.Schedule{}
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
+
+func scanSchedule(row interface{ Scan(...interface{}) error }) (models.Schedule, error) {
+	var (
+		s                    models.Schedule
+		optionsJSON          []byte
+		nextRunAt            string
+		lastRunAt            sql.NullString
+		lastJobID            sql.NullInt64
+		createdAt, updatedAt string
+	)
+	if err := row.Scan(&s.ID, &s.Name, &s.Cron, &s.URL, &optionsJSON, &s.MissedPolicy, &s.Enabled,
+		&nextRunAt, &lastRunAt, &lastJobID, &createdAt, &updatedAt); err != nil {
+		return s, err
+	}
+	if len(optionsJSON) > 0 {
+		json.Unmarshal(optionsJSON, &s.Options)
+	}
+	s.NextRunAt, _ = time.Parse(timeLayout, nextRunAt)
+	s.LastRunAt = parseNullTime(lastRunAt)
+	s.LastJobID = lastJobID.Int64
+	s.CreatedAt, _ = time.Parse(timeLayout, createdAt)
+	s.UpdatedAt, _ = time.Parse(timeLayout, updatedAt)
+	return s, nil
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// GetSetting returns a stored setting, or fallback if it is not set.
+func (s *SQLStore) GetSetting(name, fallback string) (string, error) {
+	var value string
+	err := s.db.QueryRow(`SELECT value FROM settings WHERE name = ?`, name).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fallback, nil
+	}
+	return value, err
+}
+
+// SetSetting stores a setting, replacing any earlier value.
+func (s *SQLStore) SetSetting(name, value string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO settings (name, value) VALUES (?, ?)`+s.dialect.upsert("name", "value"), name, value)
+	return err
+}
+
+// poolPausedSetting keeps the worker pool paused across restarts.
+const poolPausedSetting = "pool_paused"
+
+// PoolPaused reports whether the workers are paused.
+func (s *SQLStore) PoolPaused() (bool, error) {
+	value, err := s.GetSetting(poolPausedSetting, "false")
+	if err != nil {
+		return false, err
+	}
+	return value == "true", nil
+}
+
+// SetPoolPaused pauses or resumes the workers.
+func (s *SQLStore) SetPoolPaused(paused bool) error {
+	value := "false"
+	if paused {
+		value = "true"
+	}
+	return s.SetSetting(poolPausedSetting, value)
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+func init() {
+	sql.Register("sqlite-utc", sqliteDriver{&sqlite.Driver{}})
+}
+
+// sqliteDriver is the SQLite driver storing times the way the MySQL driver
+// sends them, in UTC as "2006-01-02 15:04:05", so that they compare and read
+// back like DATETIME values.
+type sqliteDriver struct{ driver.Driver }
+
+// sqliteConn is the part of a SQLite connection database/sql uses.
+type sqliteConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+}
+
+type utcConn struct{ sqliteConn }
+
+func (d sqliteDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return utcConn{conn.(sqliteConn)}, nil
+}
+
+// CheckNamedValue formats times; other values are converted as usual.
+func (utcConn) CheckNamedValue(v *driver.NamedValue) error {
+	if t, ok := v.Value.(time.Time); ok {
+		v.Value = t.UTC().Format(timeLayout)
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+// ConnectSQLite opens the store on the SQLite file SQLITE_PATH (default
+// scrawling.db), or in memory if it is ":memory:". It exits if the file
+// cannot be opened.
+func ConnectSQLite() *SQLStore {
+	path := os.Getenv("SQLITE_PATH")
+	if path == "" {
+		path = "scrawling.db"
+	}
+	store, err := openSQLite(path)
+	if err != nil {
+		log.Fatalf("Failed to open SQLite database %s: %v", path, err)
+	}
+	fmt.Println("Connected to SQLite successfully")
+	return store
+}
+
+// OpenSQLite opens the store on a SQLite file, creating it as needed, and
+// applies the pending migrations. path ":memory:" keeps everything in memory
+// until the store is closed, e.g. for tests.
+func OpenSQLite(path string) (*SQLStore, error) {
+	store, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := store.MigrateUp(0); err != nil {
+		store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func openSQLite(path string) (*SQLStore, error) {
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_txlock=immediate"
+	if path != ":memory:" {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+	db, err := sql.Open("sqlite-utc", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite writes one transaction at a time, and every connection to
+	// ":memory:" would open a database of its own.
+	db.SetMaxOpenConns(1)
+	return &SQLStore{db: db, dialect: sqliteDialect}, nil
+}
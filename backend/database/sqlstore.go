@@ -0,0 +1,115 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// SQLStore is the Store on a MySQL or SQLite database. The queries are
+// shared; the few that differ between the two take their SQL from the
+// store's dialect.
+type SQLStore struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+var _ Store = (*SQLStore)(nil)
+
+// Close closes the database.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// dialect holds the SQL that differs between MySQL and SQLite.
+type dialect struct {
+	// name is also the directory of the dialect's migrations.
+	name string
+	// now and nowMillis are the current UTC time to the second and to the
+	// millisecond.
+	now, nowMillis string
+	// forUpdate locks the rows a SELECT reads until the transaction ends;
+	// skipLocked also passes over rows locked by other transactions.
+	// SQLite locks the whole database for a write transaction and needs
+	// neither.
+	forUpdate, skipLocked string
+	// greatest returns the largest of its arguments.
+	greatest string
+	// nowPlus returns the current UTC time plus seconds, an SQL expression.
+	nowPlus func(seconds string) string
+	// secondsBetween returns the seconds from one DATETIME to another.
+	secondsBetween func(from, to string) string
+	// upsert ends an INSERT so that it updates columns of the row with the
+	// same key instead of failing.
+	upsert func(key string, columns ...string) string
+
+	// migrationsTable creates the schema_migrations table.
+	migrationsTable string
+	// lockMigrations and unlockMigrations keep other processes from
+	// migrating at the same time, if the database needs it.
+	lockMigrations, unlockMigrations string
+	// transactionalDDL is set if a failed migration is rolled back in full.
+	transactionalDDL bool
+	// adopt brings the tables of a database created before migrations up
+	// to migration 1.
+	adopt func(*sql.DB) error
+}
+
+var mysqlDialect = dialect{
+	name:       "mysql",
+	now:        `UTC_TIMESTAMP()`,
+	nowMillis:  `UTC_TIMESTAMP(3)`,
+	forUpdate:  ` FOR UPDATE`,
+	skipLocked: ` FOR UPDATE SKIP LOCKED`,
+	greatest:   `GREATEST`,
+	nowPlus: func(seconds string) string {
+		return `UTC_TIMESTAMP() + INTERVAL ` + seconds + ` SECOND`
+	},
+	secondsBetween: func(from, to string) string {
+		return `TIMESTAMPDIFF(SECOND, ` + from + `, ` + to + `)`
+	},
+	upsert: func(key string, columns ...string) string {
+		set := make([]string, len(columns))
+		for i, col := range columns {
+			set[i] = col + ` = VALUES(` + col + `)`
+		}
+		return ` ON DUPLICATE KEY UPDATE ` + strings.Join(set, `, `)
+	},
+	migrationsTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			dirty BOOLEAN NOT NULL DEFAULT FALSE,
+			applied_at DATETIME NOT NULL
+		)`,
+	lockMigrations:   `SELECT GET_LOCK('scrawling_db.migrations', 60)`,
+	unlockMigrations: `SELECT RELEASE_LOCK('scrawling_db.migrations')`,
+	adopt:            upgradeLegacySchema,
+}
+
+var sqliteDialect = dialect{
+	name:      "sqlite",
+	now:       `strftime('%Y-%m-%d %H:%M:%S', 'now')`,
+	nowMillis: `strftime('%Y-%m-%d %H:%M:%f', 'now')`,
+	greatest:  `MAX`,
+	nowPlus: func(seconds string) string {
+		return `datetime('now', (` + seconds + `) || ' seconds')`
+	},
+	secondsBetween: func(from, to string) string {
+		return `CAST((julianday(` + to + `) - julianday(` + from + `)) * 86400 AS INTEGER)`
+	},
+	upsert: func(key string, columns ...string) string {
+		set := make([]string, len(columns))
+		for i, col := range columns {
+			set[i] = col + ` = excluded.` + col
+		}
+		return ` ON CONFLICT (` + key + `) DO UPDATE SET ` + strings.Join(set, `, `)
+	},
+	migrationsTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			dirty INTEGER NOT NULL DEFAULT 0,
+			applied_at TEXT NOT NULL
+		)`,
+	transactionalDDL: true,
+}
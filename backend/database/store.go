@@ -0,0 +1,147 @@
+package database
+
+import (
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+// Store keeps everything the backend persists: crawl jobs and their
+// results, batches, schedules, workers and the link cache. SQLStore
+// implements it on MySQL and on SQLite.
+type Store interface {
+	JobStore
+	ResultStore
+	ScheduleStore
+
+	// GetLinkCheck returns the cached check of url if it was made at or
+	// after since.
+	GetLinkCheck(url string, since time.Time) (models.LinkCheck, bool, error)
+	// PutLinkCheck inserts or replaces the cached check of a URL.
+	PutLinkCheck(check models.LinkCheck) error
+}
+
+// JobStore keeps the crawl jobs, their batches and history, and the leases
+// workers hold on running jobs.
+type JobStore interface {
+	CreateJob(url string, opts models.CrawlOptions, batchID int64) (int64, error)
+	GetJob(id int64) (models.Job, error)
+	GetJobEvents(id int64) ([]models.JobEvent, error)
+	TransitionJob(id int64, to, message string) error
+	CancelJob(id int64) (bool, error)
+	InterruptJobs(to string, batchID int64) ([]int64, error)
+	UnfinishedJobs(filter JobFilter) ([]int64, error)
+	ActiveJobs(since time.Time) ([]models.Job, error)
+	RunningJobs() ([]models.Job, error)
+	CountJobs(state string) (int, error)
+	AverageJobDuration() (time.Duration, error)
+	StateChangesSince(afterID int64) ([]StateChange, error)
+	LastStateChangeID() (int64, error)
+
+	CreateBatch(batch models.Batch, urls []string, opts models.CrawlOptions) (int64, []int64, error)
+	GetBatch(id int64) (models.Batch, error)
+	BatchJobs(id int64) ([]models.Job, error)
+	SetBatchPaused(id int64, paused bool) error
+	PausedBatches() ([]int64, error)
+	MoveBatchJobs(batchID int64, from, to, message string) ([]int64, error)
+	PoolPaused() (bool, error)
+	SetPoolPaused(paused bool) error
+
+	ClaimJob(workerID string, lease time.Duration) (*models.Job, error)
+	RenewLeases(workerID string, ids []int64, lease time.Duration) (map[int64]string, error)
+	ReclaimJobs(maxAttempts int) (int, error)
+	ProjectTurns() (map[string]time.Time, error)
+	SetJobPhase(id int64, workerID string, phase models.JobPhase) error
+	SetJobResult(id, resultID int64) error
+	FinishJob(id int64, workerID, state, message string) (string, error)
+	RetryJob(id int64, workerID string, runAfter time.Time, message string, frontier *models.SiteFrontier) (string, error)
+	HoldJob(id int64, workerID, message string, frontier *models.SiteFrontier) (string, error)
+
+	TouchWorker(worker models.Worker) error
+	RemoveWorker(id string) error
+	ListWorkers(maxAge time.Duration) ([]models.Worker, error)
+}
+
+// ResultStore keeps the crawl results, one urls row per crawled page.
+type ResultStore interface {
+	InsertCrawlResult(result *models.CrawlResult) (int64, error)
+	SetResultTimings(id int64, timings *models.PhaseTimings) error
+	GetResult(id int64) (models.Result, error)
+	ListResults(filter ResultFilter) ([]models.Result, error)
+	JobResults(jobID int64) ([]models.Result, error)
+
+	ResultLinks(resultID int64) ([]models.PageLink, error)
+	LinkSources(filter LinkFilter) ([]models.LinkSource, error)
+}
+
+// ScheduleStore keeps the cron schedules.
+type ScheduleStore interface {
+	CreateSchedule(s models.Schedule) (int64, error)
+	GetSchedule(id int64) (models.Schedule, error)
+	ListSchedules() ([]models.Schedule, error)
+	UpdateSchedule(s models.Schedule) error
+	DeleteSchedule(id int64) error
+	DueSchedules(now time.Time) ([]models.Schedule, error)
+	ClaimScheduleRun(id int64, due, next time.Time) (bool, error)
+	SetScheduleRun(id int64, ranAt time.Time, jobID int64) error
+}
+
+// JobFilter selects jobs; zero fields match every job.
+type JobFilter struct {
+	ID      int64
+	BatchID int64
+	URL     string
+}
+
+// ResultFilter selects results; zero fields match every result.
+type ResultFilter struct {
+	// SiteID selects a site crawl: its start page and the pages under it.
+	SiteID int64
+	JobID  int64
+	URL    string
+	// Limit caps the number of results returned; 0 means no limit.
+	Limit int
+}
+
+// LinkFilter selects links of crawled pages. URL, StatusCode and Broken
+// must not all be zero.
+type LinkFilter struct {
+	URL        string
+	StatusCode int
+	// Broken selects broken links only.
+	Broken bool
+	// Limit caps the number of links returned; 0 means no limit.
+	Limit int
+}
+
+// Connect opens the store named by DATABASE_DRIVER and checks its schema,
+// applying pending migrations unless AUTO_MIGRATE is false. It exits if the
+// database cannot be opened or its schema does not match this build.
+func Connect() Store {
+	store := Open()
+	if err := store.CheckSchema(os.Getenv("AUTO_MIGRATE") != "false"); err != nil {
+		if errors.Is(err, ErrSchemaOutdated) {
+			log.Fatalf("%v; run `go run ./cmd/migrate up`", err)
+		}
+		log.Fatalf("Schema check failed: %v", err)
+	}
+	return store
+}
+
+// Open opens the store named by DATABASE_DRIVER, mysql (the default) or
+// sqlite, without checking its schema. It exits if the database cannot be
+// opened.
+func Open() *SQLStore {
+	switch driver := os.Getenv("DATABASE_DRIVER"); driver {
+	case "", "mysql":
+		return ConnectMySQL()
+	case "sqlite":
+		return ConnectSQLite()
+	default:
+		log.Fatalf("Unknown DATABASE_DRIVER %q", driver)
+		return nil
+	}
+}
@@ -0,0 +1,269 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"scrawling_dashboard/backend/models"
+)
+
+// claimable selects the queued jobs a worker may start: not waiting for a
+// retry and not in a paused batch. It takes the current time as argument.
+const claimable = `j.state = 'queued' AND (j.run_after IS NULL OR j.run_after <= ?)
+	AND (b.paused IS NULL OR NOT b.paused)`
+
+// ClaimJob starts the next queued job on workerID and leases it for lease.
+// Jobs are claimed by priority; within a priority, projects take turns in
+// the order they were last served. It returns nil if no job is waiting.
+//
+// Workers on several machines claim concurrently: locked jobs are skipped,
+// so each job is claimed once.
+func (s *SQLStore) ClaimJob(workerID string, lease time.Duration) (*models.Job, error) {
+	// Another worker may take the job picked for this turn first.
+	for try := 0; try < 3; try++ {
+		job, err := s.claimNext(workerID, lease)
+		if err != nil || job != nil {
+			return job, err
+		}
+	}
+	return nil, nil
+}
+
+func (s *SQLStore) claimNext(workerID string, lease time.Duration) (*models.Job, error) {
+	now := time.Now()
+	var (
+		priority int
+		project  string
+	)
+	err := s.db.QueryRow(`
+		SELECT j.priority, j.project FROM crawl_jobs j
+		LEFT JOIN crawl_batches b ON b.id = j.batch_id
+		LEFT JOIN crawl_project_turns t ON t.project = j.project
+		WHERE `+claimable+`
+		GROUP BY j.priority, j.project
+		ORDER BY j.priority DESC, MAX(t.claimed_at), MIN(j.id)
+		LIMIT 1`, now).Scan(&priority, &project)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow(`
+		SELECT j.id FROM crawl_jobs j
+		LEFT JOIN crawl_batches b ON b.id = j.batch_id
+		WHERE `+claimable+` AND j.priority = ? AND j.project = ?
+		ORDER BY j.id LIMIT 1`+s.dialect.skipLocked, now, priority, project).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE crawl_jobs SET state = ?, worker_id = ?,
+			lease_until = `+s.dialect.nowPlus("?")+`, interrupt_to = NULL, phase = NULL,
+			attempts = attempts + 1, started_at = ?, finished_at = NULL, run_after = NULL, updated_at = ?
+		WHERE id = ?`, models.JobRunning, workerID, leaseSeconds(lease), now, now, id); err != nil {
+		return nil, err
+	}
+	if err := insertJobEvent(tx, id, models.JobQueued, models.JobRunning, "claimed by "+workerID, now); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO crawl_project_turns (project, claimed_at) VALUES (?, `+s.dialect.nowMillis+`)`+
+		s.dialect.upsert("project", "claimed_at"), project); err != nil {
+		return nil, err
+	}
+	job, err := scanJob(tx.QueryRow(`SELECT `+jobColumns+` FROM crawl_jobs WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &job, tx.Commit()
+}
+
+// RenewLeases extends the leases workerID holds on the running jobs ids. It
+// returns, for each job, the state it was asked to stop for ("" to go on),
+// or ErrLeaseLost.Error() for jobs the worker no longer holds.
+func (s *SQLStore) RenewLeases(workerID string, ids []int64, lease time.Duration) (map[int64]string, error) {
+	result := make(map[int64]string, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := []interface{}{leaseSeconds(lease), workerID, models.JobRunning}
+	for _, id := range ids {
+		args = append(args, id)
+		result[id] = ErrLeaseLost.Error()
+	}
+	if _, err := s.db.Exec(`
+		UPDATE crawl_jobs SET lease_until = `+s.dialect.nowPlus("?")+`
+		WHERE worker_id = ? AND state = ? AND id IN (`+in+`)`, args...); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, interrupt_to FROM crawl_jobs
+		WHERE worker_id = ? AND state = ? AND id IN (`+in+`)`, args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id        int64
+			interrupt sql.NullString
+		)
+		if err := rows.Scan(&id, &interrupt); err != nil {
+			return nil, err
+		}
+		result[id] = interrupt.String
+	}
+	return result, rows.Err()
+}
+
+// ReclaimJobs takes back the running jobs whose lease expired, because their
+// worker died or lost its connection. They are queued again, or failed once
+// they have used maxAttempts attempts. It returns the number reclaimed.
+func (s *SQLStore) ReclaimJobs(maxAttempts int) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, attempts, worker_id, interrupt_to FROM crawl_jobs
+		WHERE state = ? AND (lease_until IS NULL OR lease_until < `+s.dialect.now+`)
+		ORDER BY id`+s.dialect.skipLocked, models.JobRunning)
+	if err != nil {
+		return 0, err
+	}
+	type expired struct {
+		id                  int64
+		attempts            int
+		worker, interruptTo sql.NullString
+	}
+	var jobs []expired
+	for rows.Next() {
+		var job expired
+		if err := rows.Scan(&job.id, &job.attempts, &job.worker, &job.interruptTo); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		jobs = append(jobs, job)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, job := range jobs {
+		message := "lease expired"
+		if job.worker.Valid {
+			message = fmt.Sprintf("lease of %s expired", job.worker.String)
+		}
+		to := models.JobQueued
+		switch {
+		case job.interruptTo.String == models.JobCanceled:
+			to = models.JobCanceled
+		case job.attempts >= maxAttempts:
+			to, message = models.JobError, message+" too many times"
+		default:
+			if to, err = heldState(tx, job.id); err != nil {
+				return 0, err
+			}
+		}
+		if err := setJobState(tx, job.id, models.JobRunning, to, message); err != nil {
+			return 0, err
+		}
+	}
+	return len(jobs), tx.Commit()
+}
+
+// ProjectTurns returns when each project last had a job claimed.
+func (s *SQLStore) ProjectTurns() (map[string]time.Time, error) {
+	rows, err := s.db.Query(`SELECT project, claimed_at FROM crawl_project_turns`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	turns := make(map[string]time.Time)
+	for rows.Next() {
+		var project, claimedAt string
+		if err := rows.Scan(&project, &claimedAt); err != nil {
+			return nil, err
+		}
+		turns[project], _ = time.Parse("2006-01-02 15:04:05.000", claimedAt)
+	}
+	return turns, rows.Err()
+}
+
+// TouchWorker registers a worker or refreshes its registration.
+func (s *SQLStore) TouchWorker(worker models.Worker) error {
+	stats := []byte(worker.Stats)
+	if len(stats) == 0 {
+		stats = nil
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO crawl_workers (id, host, slots, running, stats, started_at, seen_at)
+		VALUES (?, ?, ?, ?, ?, ?, `+s.dialect.now+`)`+
+		s.dialect.upsert("id", "slots", "running", "stats", "seen_at"),
+		worker.ID, worker.Host, worker.Slots, worker.Running, stats, worker.StartedAt)
+	return err
+}
+
+// RemoveWorker unregisters a worker that stops.
+func (s *SQLStore) RemoveWorker(id string) error {
+	_, err := s.db.Exec(`DELETE FROM crawl_workers WHERE id = ?`, id)
+	return err
+}
+
+// ListWorkers returns the workers seen within the last maxAge. Workers not
+// seen for a day are unregistered.
+func (s *SQLStore) ListWorkers(maxAge time.Duration) ([]models.Worker, error) {
+	if _, err := s.db.Exec(`DELETE FROM crawl_workers WHERE seen_at < ` + s.dialect.nowPlus("-86400")); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`
+		SELECT id, host, slots, running, stats, started_at, seen_at FROM crawl_workers
+		WHERE seen_at >= `+s.dialect.nowPlus("?")+` ORDER BY id`, -leaseSeconds(maxAge))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workers := []models.Worker{}
+	for rows.Next() {
+		var (
+			worker            models.Worker
+			stats             []byte
+			startedAt, seenAt string
+		)
+		if err := rows.Scan(&worker.ID, &worker.Host, &worker.Slots, &worker.Running, &stats,
+			&startedAt, &seenAt); err != nil {
+			return nil, err
+		}
+		worker.Stats = stats
+		worker.StartedAt, _ = time.Parse(timeLayout, startedAt)
+		worker.SeenAt, _ = time.Parse(timeLayout, seenAt)
+		workers = append(workers, worker)
+	}
+	return workers, rows.Err()
+}
+
+// leaseSeconds rounds a lease up to whole seconds, the precision of DATETIME.
+func leaseSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
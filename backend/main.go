@@ -15,12 +15,34 @@ func main() {
 		log.Println("No .env file found, continuing...")
 	}
 
-	database.ConnectMySQL()
+	api.UseStore(database.Connect())
+	api.StartWorkers()
+	api.StartProgressFeed()
+	api.StartScheduler()
 
 	http.HandleFunc("/api/urls", middleware.WithCORS(api.URLHandler))
+	http.HandleFunc("/api/urls/history", middleware.WithCORS(api.HistoryHandler))
+	http.HandleFunc("/api/urls/diff", middleware.WithCORS(api.DiffHandler))
+	http.HandleFunc("/api/urls/{id}/links", middleware.WithCORS(api.ResultLinksHandler))
+	http.HandleFunc("/api/links", middleware.WithCORS(api.LinksHandler))
 	http.HandleFunc("/api/crawl", middleware.WithCORS(api.CrawlHandler))
+	http.HandleFunc("/api/crawl/batch", middleware.WithCORS(api.BatchCrawlHandler))
+	http.HandleFunc("/api/batches/{id}", middleware.WithCORS(api.BatchHandler))
+	http.HandleFunc("/api/batches/{id}/cancel", middleware.WithCORS(api.CancelBatchHandler))
+	http.HandleFunc("/api/batches/{id}/pause", middleware.WithCORS(api.PauseBatchHandler))
+	http.HandleFunc("/api/batches/{id}/resume", middleware.WithCORS(api.ResumeBatchHandler))
 	http.HandleFunc("/api/stop", middleware.WithCORS(api.StopHandler))
 	http.HandleFunc("/api/progress", middleware.WithCORS(api.ProgressHandler))
+	http.HandleFunc("/api/events", middleware.WithCORS(api.EventsHandler))
+	http.HandleFunc("/api/pool", middleware.WithCORS(api.PoolHandler))
+	http.HandleFunc("/api/pool/pause", middleware.WithCORS(api.PausePoolHandler))
+	http.HandleFunc("/api/pool/resume", middleware.WithCORS(api.ResumePoolHandler))
+	http.HandleFunc("/api/jobs/{id}", middleware.WithCORS(api.JobHandler))
+	http.HandleFunc("/api/jobs/{id}/result", middleware.WithCORS(api.JobResultHandler))
+	http.HandleFunc("/api/jobs/{id}/cancel", middleware.WithCORS(api.CancelJobHandler))
+	http.HandleFunc("/api/schedules", middleware.WithCORS(api.SchedulesHandler))
+	http.HandleFunc("/api/schedules/{id}", middleware.WithCORS(api.ScheduleHandler))
+	http.HandleFunc("/api/link-cache", middleware.WithCORS(api.LinkCacheHandler))
 	http.HandleFunc("/api/login", middleware.WithCORS(api.LoginHandler))
 	http.HandleFunc("/api/logout", middleware.WithCORS(api.LogoutHandler))
 
@@ -1,25 +1,112 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
+// Reasons a link is reported as broken.
+const (
+	ReasonDNS               = "dns"
+	ReasonTimeout           = "timeout"
+	ReasonTLS               = "tls"
+	ReasonConnectionRefused = "connection_refused"
+	ReasonNetwork           = "network"
+	ReasonHTTPStatus        = "http_status"
+	ReasonRateLimited       = "rate_limited"
+)
+
 type BrokenLink struct {
 	URL        string `json:"url"`
 	StatusCode int    `json:"status_code"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// RedirectHop is one response of a redirect chain.
+type RedirectHop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+}
+
+// RedirectChain lists every response from URL to FinalURL, the final one
+// included. Loop, TooLong and Downgrade flag problematic chains.
+type RedirectChain struct {
+	URL       string        `json:"url"`
+	FinalURL  string        `json:"final_url"`
+	Hops      []RedirectHop `json:"hops"`
+	Loop      bool          `json:"loop"`
+	TooLong   bool          `json:"too_long"`
+	Downgrade bool          `json:"https_downgrade"`
+}
+
+// LinkCheck is the outcome of checking one link.
+type LinkCheck struct {
+	URL        string         `json:"url"`
+	Broken     bool           `json:"broken"`
+	StatusCode int            `json:"status_code"`
+	Reason     string         `json:"reason,omitempty"`
+	Redirects  *RedirectChain `json:"redirects,omitempty"`
+	CheckedAt  time.Time      `json:"checked_at"`
 }
 
 type CrawlResult struct {
-	URL           string            `json:"url"`
-	HTMLVersion   string            `json:"html_version"`
-	Title         string            `json:"title"`
-	Headings      map[string]int    `json:"headings"`
-	InternalLinks int               `json:"internal_links"`
-	ExternalLinks int               `json:"external_links"`
-	BrokenLinks   []BrokenLink      `json:"broken_links"`
-	HasLoginForm  bool              `json:"has_login_form"`
-	Status       string `json:"status"`        
-	ErrorMessage string `json:"error_message"`
+	URL           string          `json:"url"`
+	HTMLVersion   string          `json:"html_version"`
+	Title         string          `json:"title"`
+	Headings      map[string]int  `json:"headings"`
+	InternalLinks int             `json:"internal_links"`
+	ExternalLinks int             `json:"external_links"`
+	BrokenLinks   []BrokenLink    `json:"broken_links"`
+	Links         []PageLink      `json:"links,omitempty"`
+	HasLoginForm  bool            `json:"has_login_form"`
+	Redirects     *RedirectChain  `json:"redirects"`
+	LinkRedirects []RedirectChain `json:"link_redirects"`
+	Status        string          `json:"status"`
+	ErrorMessage  string          `json:"error_message"`
+	Depth         int             `json:"depth"`
+	ParentID      int             `json:"parent_id,omitempty"`
+	JobID         int64           `json:"job_id,omitempty"`
+	Timings       *PhaseTimings   `json:"timings,omitempty"`
+}
+
+// PageLink is one <a href> of a crawled page, stored in the links table.
+// Nofollow, Sponsored and UGC are its rel values. Links that are not checked,
+// such as mailto: links, have no status and no CheckedAt.
+type PageLink struct {
+	URL        string     `json:"url"`
+	Anchor     string     `json:"anchor"`
+	Nofollow   bool       `json:"nofollow"`
+	Sponsored  bool       `json:"sponsored"`
+	UGC        bool       `json:"ugc"`
+	External   bool       `json:"external"`
+	Broken     bool       `json:"broken"`
+	StatusCode int        `json:"status_code"`
+	Reason     string     `json:"reason,omitempty"`
+	CheckedAt  *time.Time `json:"checked_at,omitempty"`
+}
+
+// LinkSource is a page that linked to a URL when it was crawled, and the
+// link as found then.
+type LinkSource struct {
+	ResultID  int64     `json:"result_id"`
+	PageURL   string    `json:"page_url"`
+	JobID     int64     `json:"job_id,omitempty"`
+	CrawledAt time.Time `json:"crawled_at"`
+	Link      PageLink  `json:"link"`
+}
+
+// PhaseTimings are the durations of the phases of a page crawl, in
+// milliseconds. Wait is spent on robots.txt Crawl-delay and rate limits
+// before the page is requested; Total covers every phase but DBWrite.
+type PhaseTimings struct {
+	Wait           int64 `json:"wait_ms"`
+	Navigation     int64 `json:"navigation_ms"`
+	DOMExtraction  int64 `json:"dom_extraction_ms"`
+	HeadingParse   int64 `json:"heading_parse_ms"`
+	LinkCheck      int64 `json:"link_check_ms"`
+	LoginDetection int64 `json:"login_detection_ms"`
+	DBWrite        int64 `json:"db_write_ms"`
+	Total          int64 `json:"total_ms"`
 }
 
 type Link struct {
@@ -27,21 +114,302 @@ type Link struct {
 	PageURL string
 }
 
+// SiteOptions limits how far a site crawl follows internal links.
+// Include and Exclude are regular expressions matched against the URL path.
+type SiteOptions struct {
+	MaxDepth int      `json:"max_depth"`
+	MaxPages int      `json:"max_pages"`
+	Include  []string `json:"include"`
+	Exclude  []string `json:"exclude"`
+}
+
+// FrontierEntry is a page waiting to be crawled by a site crawl.
+type FrontierEntry struct {
+	URL   string `json:"url"`
+	Depth int    `json:"depth"`
+}
+
+// SiteFrontier is the progress of a site crawl: the pages still to crawl,
+// every page already seen and the number crawled. ParentID is the result
+// row the crawl stores its pages under.
+type SiteFrontier struct {
+	Pending  []FrontierEntry `json:"pending"`
+	Visited  []string        `json:"visited"`
+	Crawled  int             `json:"crawled"`
+	ParentID int64           `json:"parent_id,omitempty"`
+}
+
+// Clone returns a deep copy of f.
+func (f *SiteFrontier) Clone() *SiteFrontier {
+	c := *f
+	c.Pending = append([]FrontierEntry(nil), f.Pending...)
+	c.Visited = append([]string(nil), f.Visited...)
+	return &c
+}
+
+// CrawlOptions are the per-request settings of a crawl.
+type CrawlOptions struct {
+	Mode        string      `json:"mode"`    // "page" (default) or "site"
+	Fetcher     string      `json:"fetcher"` // "chrome" (default) or "static"
+	Site        SiteOptions `json:"site"`
+	LinkWorkers int         `json:"link_workers"`  // concurrent link checks, 0 = default
+	LinkPerHost int         `json:"link_per_host"` // concurrent link checks per host, 0 = default
+
+	// RateLimit (requests per second) and RateBurst limit each host for this
+	// crawl, on top of the global HOST_RATE_LIMIT / HOST_RATE_BURST.
+	RateLimit float64 `json:"rate_limit"`
+	RateBurst int     `json:"rate_burst"`
+
+	// IgnoreRobots skips robots.txt, for sites we own.
+	IgnoreRobots    bool   `json:"ignore_robots"`
+	RobotsUserAgent string `json:"robots_user_agent"`
+
+	// Priority is "high", "normal" (default) or "low". Jobs of the same
+	// priority are shared out fairly between projects; jobs without a
+	// Project are grouped by the user who submitted them.
+	Priority string `json:"priority"`
+	Project  string `json:"project"`
+}
+
+// Job priorities.
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+// PriorityRank orders the priority levels, highest first. Unknown
+// priorities, such as those of jobs stored before priorities existed, rank
+// as normal.
+func PriorityRank(priority string) int {
+	switch priority {
+	case PriorityHigh:
+		return 2
+	case PriorityLow:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// Job states. A job moves from queued to running and ends in done, error or
+// blocked, or in canceled when it is stopped. Jobs of a paused batch wait in
+// paused. Interrupting a running job requests canceled, queued or paused.
+const (
+	JobQueued   = "queued"
+	JobRunning  = "running"
+	JobDone     = "done"
+	JobError    = "error"
+	JobBlocked  = "blocked"
+	JobCanceled = "canceled"
+	JobPaused   = "paused"
+)
+
+// Job is a crawl request tracked in the crawl_jobs table.
+type Job struct {
+	ID           int64        `json:"id"`
+	BatchID      int64        `json:"batch_id,omitempty"`
+	URL          string       `json:"url"`
+	Options      CrawlOptions `json:"options"`
+	State        string       `json:"state"`
+	Attempts     int          `json:"attempts"`
+	ResultID     int          `json:"result_id,omitempty"`
+	ErrorMessage string       `json:"error_message,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	StartedAt    *time.Time   `json:"started_at,omitempty"`
+	FinishedAt   *time.Time   `json:"finished_at,omitempty"`
+	// RunAfter delays a queued job that waits for a retry.
+	RunAfter *time.Time `json:"run_after,omitempty"`
+	// Frontier is where an interrupted site crawl resumes.
+	Frontier *SiteFrontier `json:"-"`
+	// WorkerID is the worker that claimed the job last; LeaseUntil is when
+	// a running job is reclaimed unless that worker renews its lease.
+	WorkerID   string     `json:"worker_id,omitempty"`
+	LeaseUntil *time.Time `json:"lease_until,omitempty"`
+	// Phase is what a running job is doing.
+	Phase *JobPhase `json:"phase,omitempty"`
+}
+
+// JobPhase is the phase a running job is in, on the page at URL. Done and
+// Total count checked links while checking links.
+type JobPhase struct {
+	Phase string `json:"phase"`
+	URL   string `json:"url"`
+	Done  int    `json:"done,omitempty"`
+	Total int    `json:"total,omitempty"`
+}
+
+// Worker is a crawl worker process, registered while it runs. Stats holds
+// its throttle state and link cache statistics.
+type Worker struct {
+	ID        string          `json:"id"`
+	Host      string          `json:"host"`
+	Slots     int             `json:"slots"`
+	Running   int             `json:"running"`
+	Stats     json.RawMessage `json:"stats,omitempty"`
+	StartedAt time.Time       `json:"started_at"`
+	SeenAt    time.Time       `json:"seen_at"`
+}
+
+// Batch groups the jobs queued by one bulk submission.
+type Batch struct {
+	ID        int64     `json:"id"`
+	Source    string    `json:"source"` // "json", "csv", "text" or "sitemap"
+	Total     int       `json:"total"`
+	Accepted  int       `json:"accepted"`
+	Rejected  int       `json:"rejected"`
+	Paused    bool      `json:"paused"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Phases of a crawl, reported in progress events.
+const (
+	PhaseNavigating     = "navigating"
+	PhaseParsing        = "parsing"
+	PhaseHeadings       = "parsing_headings"
+	PhaseCheckingLinks  = "checking_links"
+	PhaseLoginDetection = "detecting_login"
+	PhaseSaving         = "saving"
+)
+
+// ProgressEvent is pushed to progress stream clients. "state" events report
+// job state changes, "phase" events what a running job is doing; for
+// checking_links Done and Total count checked links.
+type ProgressEvent struct {
+	Type    string    `json:"type"`
+	JobID   int64     `json:"job_id"`
+	BatchID int64     `json:"batch_id,omitempty"`
+	URL     string    `json:"url"`
+	State   string    `json:"state,omitempty"`
+	Phase   string    `json:"phase,omitempty"`
+	Done    int       `json:"done,omitempty"`
+	Total   int       `json:"total,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// JobEvent records one state transition of a job.
+type JobEvent struct {
+	JobID     int64     `json:"job_id"`
+	FromState string    `json:"from_state"`
+	ToState   string    `json:"to_state"`
+	Message   string    `json:"message,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Missed run policies of a schedule: runs missed while the server was down
+// are either dropped or made up for with a single run.
+const (
+	MissedSkip    = "skip"
+	MissedCatchUp = "catch_up"
+)
+
+// Schedule enqueues a crawl of URL with Options whenever its cron
+// expression is due.
+type Schedule struct {
+	ID           int64        `json:"id"`
+	Name         string       `json:"name"`
+	Cron         string       `json:"cron"`
+	URL          string       `json:"url"`
+	Options      CrawlOptions `json:"options"`
+	MissedPolicy string       `json:"missed_policy"`
+	Enabled      bool         `json:"enabled"`
+	NextRunAt    time.Time    `json:"next_run_at"`
+	LastRunAt    *time.Time   `json:"last_run_at,omitempty"`
+	LastJobID    int64        `json:"last_job_id,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
 type RequestPayload struct {
 	URL string `json:"url"`
+	// JobID and BatchID select the jobs of /api/stop.
+	JobID   int64 `json:"job_id"`
+	BatchID int64 `json:"batch_id"`
+	CrawlOptions
+
+	// Sitemap seeds the queue with every <loc> of a sitemap instead of URL.
+	// DiscoverSitemap looks the sitemaps of URL's host up in robots.txt.
+	Sitemap         string `json:"sitemap"`
+	DiscoverSitemap bool   `json:"discover_sitemap"`
+	LastModSince    string `json:"lastmod_since"`
 }
 
 type Result struct {
-	ID            int            `json:"id"`
+	ID            int             `json:"id"`
+	URL           string          `json:"url"`
+	HTMLVersion   string          `json:"html_version"`
+	Title         string          `json:"title"`
+	Headings      map[string]int  `json:"headings"`
+	InternalLinks int             `json:"internal_links"`
+	ExternalLinks int             `json:"external_links"`
+	BrokenLinks   []BrokenLink    `json:"broken_links"`
+	HasLoginForm  bool            `json:"has_login_form"`
+	Redirects     *RedirectChain  `json:"redirects"`
+	LinkRedirects []RedirectChain `json:"link_redirects"`
+	Status        string          `json:"status"`
+	ErrorMessage  string          `json:"error_message"`
+	Depth         int             `json:"depth"`
+	ParentID      int             `json:"parent_id,omitempty"`
+	JobID         int64           `json:"job_id,omitempty"`
+	Timings       *PhaseTimings   `json:"timings,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// ResultRun identifies one crawl of a URL.
+type ResultRun struct {
+	ResultID  int       `json:"result_id"`
+	JobID     int64     `json:"job_id,omitempty"`
+	CrawledAt time.Time `json:"crawled_at"`
+	Status    string    `json:"status"`
+}
+
+// StringChange is a text that differs between two runs.
+type StringChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// BoolChange is a flag that differs between two runs.
+type BoolChange struct {
+	From bool `json:"from"`
+	To   bool `json:"to"`
+}
+
+// ResultDiff compares two runs of the same URL. Fields are only set where
+// the runs differ; Headings holds the change in count of each heading tag.
+// Links are only compared, and LinksCompared set, when both runs stored
+// their links.
+type ResultDiff struct {
 	URL           string         `json:"url"`
-	HTMLVersion   string         `json:"html_version"`
-	Title         string         `json:"title"`
-	Headings      map[string]int `json:"headings"`
-	InternalLinks int            `json:"internal_links"`
-	ExternalLinks int            `json:"external_links"`
-	BrokenLinks   []BrokenLink   `json:"broken_links"`
-	HasLoginForm  bool           `json:"has_login_form"`
-	Status        string         `json:"status"`
-	ErrorMessage  string         `json:"error_message"`
-	CreatedAt     time.Time      `json:"created_at"`
+	From          ResultRun      `json:"from"`
+	To            ResultRun      `json:"to"`
+	Changed       bool           `json:"changed"`
+	Status        *StringChange  `json:"status,omitempty"`
+	Title         *StringChange  `json:"title,omitempty"`
+	HTMLVersion   *StringChange  `json:"html_version,omitempty"`
+	HasLoginForm  *BoolChange    `json:"has_login_form,omitempty"`
+	Headings      map[string]int `json:"headings,omitempty"`
+	LinksCompared bool           `json:"links_compared"`
+	LinksAdded    []string       `json:"links_added,omitempty"`
+	LinksRemoved  []string       `json:"links_removed,omitempty"`
+	// BrokenNew are broken in To but not in From; BrokenFixed were broken
+	// in From and are not in To, either working or no longer linked.
+	BrokenNew   []BrokenLink `json:"broken_new,omitempty"`
+	BrokenFixed []BrokenLink `json:"broken_fixed,omitempty"`
+}
+
+// HistoryEntry is one run in the crawl history of a URL. Changes names what
+// differs from the run before it: status, title, html_version,
+// has_login_form, headings, link_counts, broken_new and broken_fixed.
+type HistoryEntry struct {
+	ResultRun
+	Title         string   `json:"title"`
+	HTMLVersion   string   `json:"html_version"`
+	HasLoginForm  bool     `json:"has_login_form"`
+	InternalLinks int      `json:"internal_links"`
+	ExternalLinks int      `json:"external_links"`
+	BrokenLinks   int      `json:"broken_links"`
+	ErrorMessage  string   `json:"error_message,omitempty"`
+	Changes       []string `json:"changes,omitempty"`
 }
@@ -0,0 +1,135 @@
+// Package scheduler enqueues crawls for the cron schedules stored in the
+// database.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"scrawling_dashboard/backend/database"
+	"scrawling_dashboard/backend/models"
+)
+
+const (
+	// DefaultInterval is how often due schedules are looked up.
+	DefaultInterval = 30 * time.Second
+
+	// missedGrace is how late a run may start before it counts as missed.
+	missedGrace = 2 * time.Minute
+)
+
+var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Parse checks a standard five-field cron expression. Descriptors such as
+// @daily and a CRON_TZ= prefix are accepted; other times use the server's
+// time zone.
+func Parse(expr string) (cron.Schedule, error) {
+	schedule, err := parser.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression: %w", err)
+	}
+	return schedule, nil
+}
+
+// NextRun returns the first time after after at which expr is due.
+func NextRun(expr string, after time.Time) (time.Time, error) {
+	schedule, err := Parse(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return schedule.Next(after.In(time.Local)), nil
+}
+
+// Scheduler polls the schedules table and enqueues a job for each due run.
+// All state lives in the database, so schedules survive restarts and
+// several processes can run a Scheduler without enqueuing a run twice.
+type Scheduler struct {
+	Store    database.ScheduleStore
+	Enqueue  func(url string, opts models.CrawlOptions) (int64, error)
+	Interval time.Duration
+}
+
+// New returns a scheduler that runs the schedules of store and hands due
+// runs to enqueue.
+func New(store database.ScheduleStore, enqueue func(url string, opts models.CrawlOptions) (int64, error)) *Scheduler {
+	return &Scheduler{Store: store, Enqueue: enqueue, Interval: DefaultInterval}
+}
+
+// Run checks for due schedules every Interval until ctx is done. The first
+// check happens right away, which is when runs missed while the server was
+// down are handled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		s.RunDue(time.Now())
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RunDue enqueues the runs of every schedule due at now.
+func (s *Scheduler) RunDue(now time.Time) {
+	schedules, err := s.Store.DueSchedules(now)
+	if err != nil {
+		log.Printf("Failed to load due schedules: %v\n", err)
+		return
+	}
+	for _, schedule := range schedules {
+		if err := s.run(schedule, now); err != nil {
+			log.Printf("Schedule %d failed: %v\n", schedule.ID, err)
+		}
+	}
+}
+
+// run claims the due runs of a schedule and enqueues its crawl. Runs that
+// were due more than missedGrace ago were missed. A run that is on time
+// always starts; when only missed runs are due, the skip policy drops them
+// and the catch_up policy makes up for all of them with a single crawl.
+func (s *Scheduler) run(schedule models.Schedule, now time.Time) error {
+	cronSchedule, err := Parse(schedule.Cron)
+	if err != nil {
+		return err
+	}
+
+	missed := 0
+	last := schedule.NextRunAt
+	for {
+		if now.Sub(last) > missedGrace {
+			missed++
+		}
+		next := cronSchedule.Next(last.In(time.Local))
+		if next.After(now) {
+			break
+		}
+		last = next
+	}
+	next := cronSchedule.Next(last.In(time.Local))
+	onTime := now.Sub(last) <= missedGrace
+
+	claimed, err := s.Store.ClaimScheduleRun(schedule.ID, schedule.NextRunAt, next)
+	if err != nil || !claimed {
+		return err
+	}
+
+	if missed > 0 {
+		log.Printf("Schedule %d missed %d run(s), policy %s\n", schedule.ID, missed, schedule.MissedPolicy)
+	}
+	if !onTime && schedule.MissedPolicy != models.MissedCatchUp {
+		return nil
+	}
+
+	jobID, err := s.Enqueue(schedule.URL, schedule.Options)
+	if err != nil {
+		return err
+	}
+	log.Printf("Schedule %d enqueued job %d for %s\n", schedule.ID, jobID, schedule.URL)
+	return s.Store.SetScheduleRun(schedule.ID, now, jobID)
+}
@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"scrawling_dashboard/backend/database"
+	"scrawling_dashboard/backend/models"
+)
+
+func TestParse(t *testing.T) {
+	for _, expr := range []string{"*/15 * * * *", "0 3 * * 1-5", "@daily", "CRON_TZ=Europe/Berlin 0 9 * * *"} {
+		if _, err := Parse(expr); err != nil {
+			t.Errorf("Parse(%q): %v", expr, err)
+		}
+	}
+	for _, expr := range []string{"", "* * * *", "0 0 0 * * *", "61 * * * *", "@sometimes"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) accepted an invalid expression", expr)
+		}
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	after := time.Date(2025, 3, 10, 10, 7, 30, 0, time.Local)
+	for expr, want := range map[string]time.Time{
+		"*/15 * * * *": time.Date(2025, 3, 10, 10, 15, 0, 0, time.Local),
+		"0 3 * * *":    time.Date(2025, 3, 11, 3, 0, 0, 0, time.Local),
+		"@hourly":      time.Date(2025, 3, 10, 11, 0, 0, 0, time.Local),
+	} {
+		got, err := NextRun(expr, after)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("NextRun(%q) = %v, want %v", expr, got, want)
+		}
+	}
+
+	got, err := NextRun("CRON_TZ=UTC 0 12 * * *", time.Date(2025, 3, 10, 13, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2025, 3, 11, 12, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("NextRun with CRON_TZ = %v, want %v", got, want)
+	}
+}
+
+func TestRunDue(t *testing.T) {
+	store, err := database.OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	at := func(hour, min int) time.Time { return time.Date(2025, 3, 10, hour, min, 0, 0, time.Local) }
+	now := at(10, 30)
+	ids := make(map[string]int64)
+	for _, schedule := range []models.Schedule{
+		{URL: "https://on-time.example/", Cron: "30 * * * *", NextRunAt: at(10, 30), Enabled: true},
+		{URL: "https://skip.example/", Cron: "0 * * * *", NextRunAt: at(7, 0), MissedPolicy: models.MissedSkip, Enabled: true},
+		{URL: "https://catch-up.example/", Cron: "0 * * * *", NextRunAt: at(7, 0), MissedPolicy: models.MissedCatchUp, Enabled: true},
+		{URL: "https://later.example/", Cron: "0 * * * *", NextRunAt: at(11, 0), Enabled: true},
+		{URL: "https://disabled.example/", Cron: "0 * * * *", NextRunAt: at(7, 0), MissedPolicy: models.MissedCatchUp},
+	} {
+		schedule.Name = schedule.URL
+		id, err := store.CreateSchedule(schedule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[schedule.URL] = id
+	}
+
+	enqueued := make(map[string]int64)
+	s := New(store, func(url string, opts models.CrawlOptions) (int64, error) {
+		enqueued[url] = int64(len(enqueued) + 100)
+		return enqueued[url], nil
+	})
+	s.RunDue(now)
+	// The runs are claimed, so a second scheduler polling at the same time
+	// enqueues nothing.
+	s.RunDue(now)
+
+	// Missed runs of the skip policy are dropped; catch_up makes up for
+	// all of them with one crawl.
+	if len(enqueued) != 2 || enqueued["https://on-time.example/"] == 0 || enqueued["https://catch-up.example/"] == 0 {
+		t.Errorf("enqueued %v, want the on-time and catch-up schedules once each", enqueued)
+	}
+
+	for url, next := range map[string]time.Time{
+		"https://on-time.example/":  at(11, 30),
+		"https://skip.example/":     at(11, 0),
+		"https://catch-up.example/": at(11, 0),
+		"https://later.example/":    at(11, 0),
+	} {
+		schedule, err := store.GetSchedule(ids[url])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !schedule.NextRunAt.Equal(next) {
+			t.Errorf("%s: next run at %v, want %v", url, schedule.NextRunAt, next)
+		}
+	}
+	if schedule, _ := store.GetSchedule(ids["https://catch-up.example/"]); schedule.LastJobID != enqueued[schedule.URL] || schedule.LastRunAt == nil {
+		t.Errorf("catch-up schedule = %+v, want its run recorded with job %d", schedule, enqueued[schedule.URL])
+	}
+}
@@ -0,0 +1,246 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"scrawling_dashboard/backend/crawler"
+	"scrawling_dashboard/backend/database"
+	"scrawling_dashboard/backend/models"
+)
+
+// phaseInterval limits how often link check progress is recorded for one job.
+const phaseInterval = 500 * time.Millisecond
+
+// errJobCanceled is the error recorded for jobs stopped while running.
+var errJobCanceled = errors.New("canceled by user")
+
+// run crawls a claimed job and records how it ended: done, failed, retried
+// later, or back in the queue if it was interrupted by a pause.
+func (p *Pool) run(ctx context.Context, t *task) {
+	job := &t.job
+	state, err := p.runJob(ctx, t)
+
+	p.mu.Lock()
+	interrupt, reason := t.interrupt, t.message
+	p.mu.Unlock()
+
+	var dbErr error
+	switch {
+	case interrupt == interruptLost:
+		log.Printf("Job %d: lease lost, dropping this run\n", job.ID)
+		return
+	case (interrupt == models.JobQueued || interrupt == models.JobPaused) && state != models.JobDone:
+		_, dbErr = p.Store.HoldJob(job.ID, p.ID, reason, job.Frontier)
+	case interrupt != models.JobCanceled && state == models.JobError && p.Retry.ShouldRetry(err, job.Attempts):
+		delay := p.Retry.Backoff(job.Attempts)
+		message := fmt.Sprintf("attempt %d failed (%s): %v; retrying in %s",
+			job.Attempts, crawler.ErrorClass(err), err, delay.Round(time.Second))
+		log.Printf("Job %d: %s\n", job.ID, message)
+		_, dbErr = p.Store.RetryJob(job.ID, p.ID, time.Now().Add(delay), message, job.Frontier)
+	default:
+		if interrupt == models.JobCanceled {
+			state, err = models.JobCanceled, errJobCanceled
+		}
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+			if job.Options.Mode != "site" {
+				p.storeFailure(*job, state, errMsg)
+			}
+		}
+		_, dbErr = p.Store.FinishJob(job.ID, p.ID, state, errMsg)
+	}
+	if errors.Is(dbErr, database.ErrLeaseLost) {
+		log.Printf("Job %d: lease lost before the run was recorded\n", job.ID)
+	} else if dbErr != nil {
+		log.Printf("Failed to record job %d: %v\n", job.ID, dbErr)
+	}
+}
+
+// runJob crawls a job and stores its results. It returns the state the job
+// ends in and the crawl error, if any; a result that cannot be stored fails
+// the job with crawler.ErrNotStored. Failed page crawls are stored by the
+// caller once no retry is left. An interrupted site crawl leaves its
+// frontier in the task's job.
+func (p *Pool) runJob(ctx context.Context, t *task) (string, error) {
+	job := &t.job
+	url := job.URL
+
+	c, err := p.newCrawler(job.Options, t.limiter)
+	if err != nil {
+		// Options are validated on submit, so this only happens if the
+		// environment default is misconfigured.
+		log.Printf("Crawling failed for %s: %v\n", url, err)
+		return models.JobError, err
+	}
+	c.Progress = func(progress crawler.Progress) { p.reportPhase(t, progress) }
+
+	if job.Options.Mode == "site" {
+		parentID, err := p.crawlSite(ctx, c, t)
+		if parentID > 0 {
+			p.setJobResult(job.ID, parentID)
+		}
+		if err != nil {
+			log.Printf("Site crawl failed for %s: %v\n", url, err)
+			return models.JobError, err
+		}
+		log.Printf("Site crawl completed for %s\n", url)
+		return models.JobDone, nil
+	}
+
+	result, err := c.CrawlURL(ctx, url)
+	if err != nil || result == nil {
+		if err == nil {
+			err = errors.New("Unknown error")
+		}
+		log.Printf("Crawling failed for %s (attempt %d): %v\n", url, job.Attempts, err)
+		if errors.Is(err, crawler.ErrBlockedByRobots) {
+			return models.JobBlocked, err
+		}
+		return models.JobError, err
+	}
+
+	result.Status = models.JobDone
+	result.JobID = job.ID
+	id, dbErr := p.saveResult(t, result)
+	if dbErr != nil {
+		log.Printf("DB insert error (success case): %v\n", dbErr)
+		return models.JobError, fmt.Errorf("%w: %v", crawler.ErrNotStored, dbErr)
+	}
+	p.setJobResult(job.ID, id)
+	log.Printf("Crawling completed for %s\n", url)
+	return models.JobDone, nil
+}
+
+// crawlSite runs a site crawl, or resumes it from the job's frontier, and
+// stores every page under the row of the start page, whose ID it returns.
+// A page that cannot be stored stops the crawl with crawler.ErrNotStored.
+// When the crawl is interrupted or stopped its frontier is kept in the job.
+func (p *Pool) crawlSite(ctx context.Context, c *crawler.Crawler, t *task) (int64, error) {
+	job := &t.job
+	var parentID int64
+	if job.Frontier != nil {
+		parentID = job.Frontier.ParentID
+	}
+	frontier, err := c.ResumeSite(ctx, job.URL, job.Options.Site, job.Frontier, func(page *models.CrawlResult) error {
+		page.ParentID = int(parentID)
+		page.JobID = job.ID
+		id, err := p.saveResult(t, page)
+		if err != nil {
+			log.Printf("DB insert error (site page %s): %v\n", page.URL, err)
+			return fmt.Errorf("%w: page %s: %v", crawler.ErrNotStored, page.URL, err)
+		}
+		if parentID == 0 {
+			parentID = id
+		}
+		return nil
+	})
+	if err != nil && frontier != nil {
+		frontier.ParentID = parentID
+		job.Frontier = frontier
+	}
+	return parentID, err
+}
+
+// reportPhase records the phase of a running job. Link check progress is
+// recorded at most every phaseInterval.
+func (p *Pool) reportPhase(t *task, progress crawler.Progress) {
+	now := time.Now()
+
+	p.mu.Lock()
+	throttled := progress.Phase == t.phase && progress.Phase == models.PhaseCheckingLinks &&
+		progress.Done < progress.Total && now.Sub(t.phaseAt) < phaseInterval
+	if !throttled {
+		t.phase, t.phaseAt = progress.Phase, now
+	}
+	p.mu.Unlock()
+
+	if throttled {
+		return
+	}
+	err := p.Store.SetJobPhase(t.job.ID, p.ID, models.JobPhase{
+		Phase: progress.Phase,
+		URL:   progress.URL,
+		Done:  progress.Done,
+		Total: progress.Total,
+	})
+	if err != nil {
+		log.Printf("Failed to record phase of job %d: %v\n", t.job.ID, err)
+	}
+}
+
+// saveResult stores the result of a crawled page and adds the time the insert
+// took to its phase timings.
+func (p *Pool) saveResult(t *task, result *models.CrawlResult) (int64, error) {
+	p.reportPhase(t, crawler.Progress{URL: result.URL, Phase: models.PhaseSaving})
+	start := time.Now()
+	id, err := p.Store.InsertCrawlResult(result)
+	if err != nil || result.Timings == nil {
+		return id, err
+	}
+	result.Timings.DBWrite = time.Since(start).Milliseconds()
+	if err := p.Store.SetResultTimings(id, result.Timings); err != nil {
+		log.Printf("Failed to store timings of result %d: %v\n", id, err)
+	}
+	return id, nil
+}
+
+// storeFailure stores the error row of a page crawl that failed for good.
+func (p *Pool) storeFailure(job models.Job, state, errMsg string) {
+	id, err := p.Store.InsertCrawlResult(&models.CrawlResult{
+		URL:          job.URL,
+		JobID:        job.ID,
+		Status:       state,
+		ErrorMessage: errMsg,
+	})
+	if err != nil {
+		log.Printf("DB insert error (error case): %v\n", err)
+		return
+	}
+	p.setJobResult(job.ID, id)
+}
+
+func (p *Pool) setJobResult(id, resultID int64) {
+	if err := p.Store.SetJobResult(id, resultID); err != nil {
+		log.Printf("Failed to link job %d to result %d: %v\n", id, resultID, err)
+	}
+}
+
+// newCrawlLimiter returns the per-host limiter of a crawl that sets its own
+// rate limit, or nil.
+func newCrawlLimiter(opts models.CrawlOptions) *crawler.HostLimiter {
+	if opts.RateLimit <= 0 && opts.RateBurst <= 0 {
+		return nil
+	}
+	return crawler.NewHostLimiter(opts.RateLimit, opts.RateBurst)
+}
+
+// newCrawler builds a crawler for the fetch mode, link check limits,
+// robots.txt and rate limit settings of a job.
+func (p *Pool) newCrawler(opts models.CrawlOptions, limiter *crawler.HostLimiter) (*crawler.Crawler, error) {
+	fetcher, err := crawler.NewFetcher(opts.Fetcher, p.browser)
+	if err != nil {
+		return nil, err
+	}
+	c := crawler.New(fetcher)
+	c.Limiter = crawler.Limiters{p.hosts}
+	if limiter != nil {
+		c.Limiter = append(c.Limiter, limiter)
+	}
+	c.Links = crawler.NewLinkChecker(opts.LinkWorkers, opts.LinkPerHost)
+	c.Links.Cache = p.LinkCache
+	c.Links.Hosts = p.linkHosts
+	c.Links.Limiter = c.Limiter
+	if !opts.IgnoreRobots {
+		c.Robots = p.robots
+		c.RobotsAgent = opts.RobotsUserAgent
+		if c.RobotsAgent == "" {
+			c.RobotsAgent = crawler.RobotsAgent()
+		}
+	}
+	return c, nil
+}
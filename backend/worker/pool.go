@@ -0,0 +1,392 @@
+// Package worker runs the crawl jobs queued in the crawl_jobs table. Pools
+// in any number of processes, on any number of machines, share the queue:
+// a claimed job is leased to its worker, which renews the lease while the
+// job runs, and the jobs of workers that stop renewing are reclaimed.
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"scrawling_dashboard/backend/crawler"
+	"scrawling_dashboard/backend/database"
+	"scrawling_dashboard/backend/models"
+)
+
+const (
+	DefaultSlots     = 4
+	DefaultLease     = time.Minute
+	DefaultHeartbeat = 5 * time.Second
+	DefaultPoll      = 2 * time.Second
+
+	// browserIdleTimeout is how long Chrome is kept running without jobs.
+	browserIdleTimeout = 30 * time.Second
+)
+
+// interruptLost marks a job whose lease was lost; its run is dropped.
+const interruptLost = "lost"
+
+// Pool runs up to Slots jobs at once. Jobs are polled for every Poll, or
+// at once after Wake. Leases last Lease and are renewed every Heartbeat,
+// which is also how often running jobs see cancel and pause requests.
+type Pool struct {
+	ID        string
+	Slots     int
+	Lease     time.Duration
+	Heartbeat time.Duration
+	Poll      time.Duration
+
+	// Store holds the job queue and receives the results.
+	Store database.Store
+	// Retry decides which failed crawls are tried again.
+	Retry crawler.RetryPolicy
+	// LinkCache is shared by all crawls so common links are checked once per TTL.
+	LinkCache *crawler.LinkCache
+
+	// browser is shared by all jobs; each crawl opens its own tab. It is
+	// shut down after browserIdleTimeout without jobs.
+	browser *crawler.Browser
+	// robots is shared so robots.txt is fetched once per host.
+	robots *crawler.RobotsCache
+	// hosts throttles every request to a host, across all jobs of the pool.
+	hosts *crawler.HostLimiter
+	// linkHosts caps concurrent link checks per host across all jobs.
+	linkHosts *crawler.HostSlots
+
+	host    string
+	started time.Time
+	wake    chan struct{}
+	check   chan struct{}
+	wg      sync.WaitGroup
+
+	mu          sync.Mutex
+	running     map[int64]*task
+	idleSince   time.Time
+	browserIdle bool
+}
+
+// task is a job running in the pool.
+type task struct {
+	job     models.Job
+	cancel  context.CancelFunc
+	limiter *crawler.HostLimiter // the job's own rate limit, or nil
+
+	// interrupt is the state the job was stopped for: canceled, queued or
+	// paused, or interruptLost; message explains it in the job's history.
+	interrupt string
+	message   string
+
+	phase   string
+	phaseAt time.Time // when the phase was last recorded
+}
+
+// Stats is what a worker reports about itself in the worker registry.
+type Stats struct {
+	Throttle  Throttle               `json:"throttle"`
+	LinkCache crawler.LinkCacheStats `json:"link_cache"`
+}
+
+// Throttle is the per-host throttle state of a pool, globally and for jobs
+// with their own rate limit.
+type Throttle struct {
+	Hosts  map[string]crawler.HostThrottle           `json:"hosts"`
+	Crawls map[int64]map[string]crawler.HostThrottle `json:"crawls"`
+}
+
+// New returns a pool running the jobs of store, configured from
+// CRAWL_WORKERS, CRAWL_LEASE, CRAWL_HEARTBEAT and CRAWL_POLL.
+// LINK_CACHE_STORE=db persists link checks in store in addition to memory.
+func New(store database.Store) *Pool {
+	host, _ := os.Hostname()
+	if len(host) > 40 {
+		host = host[:40]
+	}
+	p := &Pool{
+		ID:        fmt.Sprintf("%s-%d-%04x", host, os.Getpid(), rand.Intn(0x10000)),
+		Slots:     envInt("CRAWL_WORKERS", DefaultSlots),
+		Lease:     envDuration("CRAWL_LEASE", DefaultLease),
+		Heartbeat: envDuration("CRAWL_HEARTBEAT", DefaultHeartbeat),
+		Poll:      envDuration("CRAWL_POLL", DefaultPoll),
+		Store:     store,
+		Retry:     crawler.NewRetryPolicy(),
+		LinkCache: crawler.NewLinkCache(0, nil),
+		browser:   crawler.NewBrowser(),
+		robots:    crawler.NewRobotsCache(),
+		hosts:     crawler.NewHostLimiter(0, 0),
+		linkHosts: crawler.NewHostSlots(0),
+		host:      host,
+		wake:      make(chan struct{}, 1),
+		check:     make(chan struct{}, 1),
+		running:   make(map[int64]*task),
+	}
+	// A lease must outlive a few missed heartbeats.
+	p.Heartbeat = min(p.Heartbeat, p.Lease/3)
+	// "mysql" is the value from before the store could be SQLite.
+	if cache := os.Getenv("LINK_CACHE_STORE"); cache == "db" || cache == "mysql" {
+		p.LinkCache.Store = store
+	}
+	return p
+}
+
+// Run claims and runs jobs until ctx is done. The running jobs are then
+// interrupted and queued again, site crawls with their frontier, so other
+// workers resume them.
+func (p *Pool) Run(ctx context.Context) {
+	p.started = time.Now()
+	log.Printf("Worker %s started with %d slot(s)\n", p.ID, p.Slots)
+	p.touch()
+
+	heartbeatDone := make(chan struct{})
+	go func() {
+		p.heartbeatLoop(ctx)
+		close(heartbeatDone)
+	}()
+
+	for {
+		p.claim(ctx)
+		p.closeIdleBrowser()
+		select {
+		case <-ctx.Done():
+			p.stop()
+			<-heartbeatDone
+			if err := p.Store.RemoveWorker(p.ID); err != nil {
+				log.Printf("Failed to unregister worker %s: %v\n", p.ID, err)
+			}
+			p.browser.Close()
+			log.Printf("Worker %s stopped\n", p.ID)
+			return
+		case <-p.wake:
+		case <-time.After(p.Poll):
+		}
+	}
+}
+
+// Wake makes the pool look for jobs and for cancel and pause requests now
+// rather than at its next poll.
+func (p *Pool) Wake() {
+	for _, ch := range []chan struct{}{p.wake, p.check} {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// claim starts queued jobs while the pool has free slots, unless the pool
+// is paused.
+func (p *Pool) claim(ctx context.Context) {
+	paused, err := p.Store.PoolPaused()
+	if err != nil {
+		log.Printf("Failed to read pool state: %v\n", err)
+		return
+	}
+	if paused {
+		return
+	}
+	for ctx.Err() == nil {
+		p.mu.Lock()
+		free := len(p.running) < p.Slots
+		p.mu.Unlock()
+		if !free {
+			return
+		}
+		job, err := p.Store.ClaimJob(p.ID, p.Lease)
+		if err != nil {
+			log.Printf("Failed to claim a job: %v\n", err)
+			return
+		}
+		if job == nil {
+			return
+		}
+		p.start(*job)
+	}
+}
+
+// start runs a claimed job in its own goroutine.
+func (p *Pool) start(job models.Job) {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if job.Options.Mode == "site" {
+		// Each page of a site crawl has its own timeout.
+		ctx, cancel = context.WithCancel(context.Background())
+	} else {
+		ctx, cancel = context.WithTimeout(context.Background(), 2*time.Minute)
+	}
+	t := &task{job: job, cancel: cancel, limiter: newCrawlLimiter(job.Options)}
+
+	p.mu.Lock()
+	p.running[job.ID] = t
+	p.idleSince = time.Time{}
+	p.browserIdle = false
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.run(ctx, t)
+		cancel()
+
+		p.mu.Lock()
+		delete(p.running, job.ID)
+		p.mu.Unlock()
+		// Claim the next job for the freed slot.
+		select {
+		case p.wake <- struct{}{}:
+		default:
+		}
+	}()
+}
+
+// stop interrupts the running jobs and waits for them to be recorded.
+func (p *Pool) stop() {
+	p.mu.Lock()
+	for id := range p.running {
+		p.interruptLocked(id, models.JobQueued, "worker stopped")
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// heartbeatLoop renews the leases of the running jobs and reclaims expired
+// ones every Heartbeat until ctx is done.
+func (p *Pool) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(p.Heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-p.check:
+		}
+		p.heartbeat()
+	}
+}
+
+func (p *Pool) heartbeat() {
+	p.mu.Lock()
+	ids := make([]int64, 0, len(p.running))
+	for id := range p.running {
+		ids = append(ids, id)
+	}
+	p.mu.Unlock()
+
+	requests, err := p.Store.RenewLeases(p.ID, ids, p.Lease)
+	if err != nil {
+		log.Printf("Failed to renew leases of worker %s: %v\n", p.ID, err)
+	}
+	p.mu.Lock()
+	for id, request := range requests {
+		switch request {
+		case "":
+		case database.ErrLeaseLost.Error():
+			p.interruptLocked(id, interruptLost, "")
+		case models.JobQueued:
+			p.interruptLocked(id, request, "interrupted by pool pause")
+		case models.JobPaused:
+			p.interruptLocked(id, request, "batch paused")
+		default:
+			p.interruptLocked(id, request, "")
+		}
+	}
+	p.mu.Unlock()
+
+	if n, err := p.Store.ReclaimJobs(p.Retry.MaxAttempts); err != nil {
+		log.Printf("Failed to reclaim expired jobs: %v\n", err)
+	} else if n > 0 {
+		log.Printf("Reclaimed %d job(s) with an expired lease\n", n)
+		p.Wake()
+	}
+	p.touch()
+}
+
+// interruptLocked stops a running job for the given reason, unless it is
+// already being stopped. p.mu must be held.
+func (p *Pool) interruptLocked(id int64, reason, message string) {
+	t, ok := p.running[id]
+	if !ok || t.interrupt != "" {
+		return
+	}
+	t.interrupt, t.message = reason, message
+	t.cancel()
+}
+
+// touch registers the pool in the worker registry.
+func (p *Pool) touch() {
+	p.mu.Lock()
+	running := len(p.running)
+	p.mu.Unlock()
+
+	stats, _ := json.Marshal(p.Stats())
+	err := p.Store.TouchWorker(models.Worker{
+		ID:        p.ID,
+		Host:      p.host,
+		Slots:     p.Slots,
+		Running:   running,
+		Stats:     stats,
+		StartedAt: p.started,
+	})
+	if err != nil {
+		log.Printf("Failed to register worker %s: %v\n", p.ID, err)
+	}
+}
+
+// Stats returns the throttle state and link cache statistics of the pool.
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	crawls := make(map[int64]map[string]crawler.HostThrottle)
+	for id, t := range p.running {
+		if t.limiter != nil {
+			crawls[id] = t.limiter.Snapshot()
+		}
+	}
+	p.mu.Unlock()
+
+	return Stats{
+		Throttle:  Throttle{Hosts: p.hosts.Snapshot(), Crawls: crawls},
+		LinkCache: p.LinkCache.Stats(),
+	}
+}
+
+// closeIdleBrowser shuts Chrome down once the pool has been idle for
+// browserIdleTimeout.
+func (p *Pool) closeIdleBrowser() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.running) > 0 || p.browserIdle {
+		return
+	}
+	if p.idleSince.IsZero() {
+		p.idleSince = time.Now()
+		return
+	}
+	if time.Since(p.idleSince) > browserIdleTimeout {
+		p.browser.Close()
+		p.browserIdle = true
+	}
+}
+
+// envInt reads a positive integer from the environment.
+func envInt(key string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
+
+// envDuration reads a positive duration such as "30s" from the environment.
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
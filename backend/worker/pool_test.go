@@ -0,0 +1,151 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"scrawling_dashboard/backend/crawler"
+	"scrawling_dashboard/backend/database"
+	"scrawling_dashboard/backend/models"
+)
+
+// newTestPool returns a pool on a migrated in-memory SQLite store.
+func newTestPool(t *testing.T) *Pool {
+	t.Helper()
+	store, err := database.OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	p := New(store)
+	p.ID = "test-worker"
+	p.Lease = time.Minute
+	return p
+}
+
+func mustCreateJob(t *testing.T, p *Pool, url string) int64 {
+	t.Helper()
+	id, err := p.Store.CreateJob(url, models.CrawlOptions{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// claimJob claims the next job of p's store as workerID. If track is set,
+// the job is tracked as running in p without being crawled.
+func claimJob(t *testing.T, p *Pool, workerID string, lease time.Duration, track bool) *task {
+	t.Helper()
+	job, err := p.Store.ClaimJob(workerID, lease)
+	if err != nil || job == nil {
+		t.Fatalf("ClaimJob = %+v, %v", job, err)
+	}
+	if !track {
+		return nil
+	}
+	tk := &task{job: *job, cancel: func() {}}
+	p.mu.Lock()
+	p.running[job.ID] = tk
+	p.mu.Unlock()
+	return tk
+}
+
+func TestHeartbeatRenewsLeases(t *testing.T) {
+	p := newTestPool(t)
+	mine := mustCreateJob(t, p, "https://a.example/")
+	orphan := mustCreateJob(t, p, "https://b.example/")
+	claimJob(t, p, p.ID, time.Second, true)
+	claimJob(t, p, "gone-worker", time.Second, false)
+
+	// Both leases expire; only the job of the pool is renewed, before the
+	// expired ones are reclaimed.
+	time.Sleep(2100 * time.Millisecond)
+	p.heartbeat()
+
+	job, err := p.Store.GetJob(mine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != models.JobRunning || job.LeaseUntil == nil || time.Until(*job.LeaseUntil) < 50*time.Second {
+		t.Errorf("job of the pool = %+v, want it running with a renewed lease", job)
+	}
+
+	job, err = p.Store.GetJob(orphan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != models.JobQueued || job.WorkerID == p.ID {
+		t.Errorf("job of the gone worker = %+v, want it queued again", job)
+	}
+	events, _ := p.Store.GetJobEvents(orphan)
+	if last := events[len(events)-1]; last.Message != "lease of gone-worker expired" {
+		t.Errorf("reclaim recorded as %q", last.Message)
+	}
+}
+
+func TestHeartbeatReclaimFailsExhaustedJob(t *testing.T) {
+	p := newTestPool(t)
+	p.Retry.MaxAttempts = 1
+	id := mustCreateJob(t, p, "https://a.example/")
+	claimJob(t, p, "gone-worker", time.Second, false)
+
+	time.Sleep(2100 * time.Millisecond)
+	p.heartbeat()
+
+	if job, err := p.Store.GetJob(id); err != nil || job.State != models.JobError {
+		t.Errorf("job = %+v, %v; want it failed after its last attempt", job, err)
+	}
+}
+
+func TestHeartbeatInterruptsJobs(t *testing.T) {
+	p := newTestPool(t)
+	canceled := mustCreateJob(t, p, "https://a.example/")
+	paused := mustCreateJob(t, p, "https://b.example/")
+	lost := mustCreateJob(t, p, "https://c.example/")
+	cancelTask := claimJob(t, p, p.ID, time.Minute, true)
+	pauseTask := claimJob(t, p, p.ID, time.Minute, true)
+	// The pool still runs a job whose lease another worker holds, e.g.
+	// after the lease expired and the job was reclaimed.
+	lostTask := claimJob(t, p, "other", time.Minute, true)
+
+	if ok, err := p.Store.CancelJob(canceled); err != nil || !ok {
+		t.Fatalf("CancelJob = %v, %v", ok, err)
+	}
+	if _, err := p.Store.InterruptJobs(models.JobPaused, 0); err != nil {
+		t.Fatal(err)
+	}
+	p.heartbeat()
+
+	for _, tt := range []struct {
+		id        int64
+		task      *task
+		interrupt string
+	}{
+		{canceled, cancelTask, models.JobCanceled},
+		{paused, pauseTask, models.JobPaused},
+		{lost, lostTask, interruptLost},
+	} {
+		if tt.task.interrupt != tt.interrupt {
+			t.Errorf("job %d interrupted for %q, want %q", tt.id, tt.task.interrupt, tt.interrupt)
+		}
+	}
+	if pauseTask.message != "batch paused" {
+		t.Errorf("pause recorded as %q", pauseTask.message)
+	}
+}
+
+func TestNewCrawlerSharesLinkHosts(t *testing.T) {
+	p := newTestPool(t)
+	a, err := p.newCrawler(models.CrawlOptions{Fetcher: crawler.FetcherStatic, LinkPerHost: 8}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := p.newCrawler(models.CrawlOptions{Fetcher: crawler.FetcherStatic}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The per-host bound holds across all jobs of the pool.
+	if a.Links == b.Links || a.Links.Hosts == nil || a.Links.Hosts != b.Links.Hosts {
+		t.Errorf("crawlers of one pool do not share their link check host slots")
+	}
+}
@@ -27,7 +27,7 @@ import DeleteIcon from '@mui/icons-material/Delete';
 
 interface UrlEntry {
   url: string;
-  status: 'queued' | 'running' | 'done' | 'error' | 'stopped';
+  status: 'queued' | 'running' | 'done' | 'error' | 'canceled' | 'paused' | 'stopped';
 }
 
 const TaskSearch = () => {
@@ -104,7 +104,7 @@ const TaskSearch = () => {
     return new Promise((resolve) => {
       const interval = setInterval(() => {
         const currentStatus = getStatus(url);
-        if (currentStatus === 'done' || currentStatus === 'error') {
+        if (currentStatus === 'done' || currentStatus === 'error' || currentStatus === 'canceled') {
           clearInterval(interval);
           resolve();
         }
@@ -41,7 +41,9 @@ const getStatusLabel = (status: string): JSX.Element => {
   const map = {
     done: { text: 'Done', color: 'success' },
     error: { text: 'Error', color: 'error' },
+    canceled: { text: 'Canceled', color: 'secondary' },
     queued: { text: 'Queued', color: 'warning' },
+    paused: { text: 'Paused', color: 'secondary' },
     running: { text: 'Running', color: 'primary' }
   };
   const { text, color }: any = map[status] || { text: status, color: 'default' };
@@ -2,7 +2,7 @@ import { createSlice, PayloadAction } from '@reduxjs/toolkit';
 
 interface UrlEntry {
   url: string;
-  status: 'queued' | 'running' | 'done' | 'error' | 'stopped';
+  status: 'queued' | 'running' | 'done' | 'error' | 'canceled' | 'paused' | 'stopped';
 }
 
 const initialState: UrlEntry[] = [];